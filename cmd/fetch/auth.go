@@ -0,0 +1,248 @@
+package fetch
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/resolve"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	git_ssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// scpLikeURL matches scp-like ssh urls such as git@github.com:owner/repo.git
+var scpLikeURL = regexp.MustCompile(`^[a-zA-Z0-9_.-]+@[a-zA-Z0-9_.-]+:`)
+
+// authenticator builds go-git auth methods out of AuthConfig entries.
+// The ssh agent connection is opened once, on first use, and shared by all the clones.
+// It is safe for concurrent use.
+type authenticator struct {
+	mu        sync.Mutex
+	hosts     map[string]host // their tokens are used for https urls when no credentials were configured
+	agentConn net.Conn
+	agent     agent.Agent
+	keys      map[string]*sshKey // key files by path, so passphrases are asked for only once
+
+	// pause, when set, stops the progress display while a passphrase is asked for, until resume is called
+	pause func() (resume func())
+}
+
+// sshKey is the signer of a key file, read on first use. Its own lock is held while its passphrase is asked for,
+// so only the clones using the same key wait for it.
+type sshKey struct {
+	mu     sync.Mutex
+	signer ssh.Signer
+}
+
+func newAuthenticator(hosts map[string]host) *authenticator {
+	return &authenticator{hosts: hosts, keys: make(map[string]*sshKey)}
+}
+
+// Close releases the ssh agent connection, if one was opened
+func (a *authenticator) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.agentConn == nil {
+		return nil
+	}
+	return a.agentConn.Close()
+}
+
+// authKind names the way a repository is authenticated against its remote
+type authKind string
+
+const (
+	authNone       authKind = "none"
+	authSSHKey     authKind = "ssh-key"
+	authSSHAgent   authKind = "ssh-agent"
+	authOAuthToken authKind = "oauth-token"
+	authBasic      authKind = "basic"
+	authHostToken  authKind = "host-token"
+)
+
+// kind tells which auth method will be used for cloning repoURL with the given config
+func (a *authenticator) kind(cfg *AuthConfig, repoURL string) authKind {
+	if cfg == nil {
+		cfg = &AuthConfig{}
+	}
+
+	if isSSHURL(repoURL) {
+		if cfg.SSHKey != "" {
+			return authSSHKey
+		}
+		return authSSHAgent
+	}
+
+	if !isHTTPURL(repoURL) {
+		return authNone
+	}
+	switch {
+	case cfg.OAuthToken != "":
+		return authOAuthToken
+	case cfg.Password != "":
+		return authBasic
+	case a.hostToken(repoURL).Token != "":
+		return authHostToken
+	}
+	return authNone
+}
+
+// method returns the auth method to use for cloning repoURL with the given config
+func (a *authenticator) method(cfg *AuthConfig, repoURL string) (transport.AuthMethod, error) {
+	switch a.kind(cfg, repoURL) {
+	case authSSHKey:
+		signer, err := a.keySigner(cfg.SSHKey)
+		if err != nil {
+			return nil, err
+		}
+		return &git_ssh.PublicKeys{User: "git", Signer: signer}, nil
+	case authSSHAgent:
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		return a.agentAuth()
+	case authOAuthToken:
+		return &http.BasicAuth{Username: "x-access-token", Password: cfg.OAuthToken}, nil
+	case authBasic:
+		return &http.BasicAuth{Username: cfg.Username, Password: cfg.Password}, nil
+	case authHostToken:
+		h := a.hostToken(repoURL)
+		return &http.BasicAuth{Username: h.cloneUsername(), Password: h.Token}, nil
+	}
+
+	return nil, nil
+}
+
+// gitEnv returns the environment making the git binary authenticate like the auth method of repoURL does.
+// Credentials go through the environment rather than the command line, where other users could see them.
+func (a *authenticator) gitEnv(cfg *AuthConfig, repoURL string) ([]string, error) {
+	switch a.kind(cfg, repoURL) {
+	case authSSHKey:
+		return []string{"GIT_SSH_COMMAND=ssh -i '" + strings.ReplaceAll(cfg.SSHKey, "'", `'\''`) + "' -o IdentitiesOnly=yes"}, nil
+	case authOAuthToken, authBasic, authHostToken:
+		method, err := a.method(cfg, repoURL)
+		if err != nil {
+			return nil, err
+		}
+		basic := method.(*http.BasicAuth)
+		credentials := base64.StdEncoding.EncodeToString([]byte(basic.Username + ":" + basic.Password))
+		return []string{
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic " + credentials,
+		}, nil
+	}
+	// the ssh agent is found by ssh itself
+	return nil, nil
+}
+
+// keySigner returns the signer of a key file, reading it and asking for its passphrase on first use
+func (a *authenticator) keySigner(path string) (ssh.Signer, error) {
+	a.mu.Lock()
+	key, ok := a.keys[path]
+	if !ok {
+		key = &sshKey{}
+		a.keys[path] = key
+	}
+	a.mu.Unlock()
+
+	key.mu.Lock()
+	defer key.mu.Unlock()
+	if key.signer == nil {
+		signer, err := getSSHKeySigner(path, a.pause)
+		if err != nil {
+			return nil, err
+		}
+		key.signer = signer
+	}
+	return key.signer, nil
+}
+
+// hostToken returns the host of an https url, with its API token
+func (a *authenticator) hostToken(repoURL string) host {
+	ref, err := resolve.ParseRepoURL(repoURL)
+	if err != nil {
+		return host{}
+	}
+	return a.hosts[ref.Host]
+}
+
+// agentAuth returns an auth method backed by the ssh agent found at SSH_AUTH_SOCK
+func (a *authenticator) agentAuth() (transport.AuthMethod, error) {
+	if a.agent == nil {
+		conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+		if err != nil {
+			return nil, fmt.Errorf("dialing the ssh agent failed: %w", err)
+		}
+		a.agentConn = conn
+		a.agent = agent.NewClient(conn)
+	}
+
+	sshAgent := a.agent
+	return &git_ssh.PublicKeysCallback{
+		User: "git",
+		Callback: func() ([]ssh.Signer, error) {
+			signers, err := sshAgent.Signers()
+			if err != nil {
+				return nil, fmt.Errorf("error occurred while getting ssh agent signers: %w", err)
+			}
+			return signers, nil
+		},
+	}, nil
+}
+
+// isSSHURL tells if the url should be cloned over ssh
+func isSSHURL(repoURL string) bool {
+	return strings.HasPrefix(repoURL, "ssh://") || scpLikeURL.MatchString(repoURL)
+}
+
+// isHTTPURL tells if the url should be cloned over http(s)
+func isHTTPURL(repoURL string) bool {
+	return strings.HasPrefix(repoURL, "https://") || strings.HasPrefix(repoURL, "http://")
+}
+
+// getSSHKeySigner reads an SSH key from the given path and returns a signer.
+// The passphrase of encrypted keys is asked for on the terminal, with the display paused by pause unless it's nil.
+func getSSHKeySigner(sshKeyPath string, pause func() (resume func())) (ssh.Signer, error) {
+	sshKey, err := os.ReadFile(sshKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while reading ssh key at %s: %w", sshKeyPath, err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(sshKey)
+	if err != nil {
+		var passphraseMissingError *ssh.PassphraseMissingError
+		needsPassphrase := errors.As(err, &passphraseMissingError)
+		if !needsPassphrase {
+			return nil, fmt.Errorf("parsing private key failed: %w", err)
+		}
+
+		if pause != nil {
+			resume := pause()
+			defer resume()
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Enter passphrase for encrypted key %s: ", sshKeyPath)
+		passphraseBytes, err := terminal.ReadPassword(int(syscall.Stdin))
+		_, _ = fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, fmt.Errorf("read passphrase error: %w", err)
+		}
+		passphrase := strings.TrimSpace(string(passphraseBytes))
+
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(sshKey, []byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("parsing private key with passphrase failed: %w", err)
+		}
+	}
+	return signer, nil
+}
@@ -0,0 +1,80 @@
+package fetch
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"golang.org/x/crypto/ssh"
+)
+
+// writeSSHKey writes a new ed25519 key file, encrypted when passphrase isn't empty, and returns its path
+func writeSSHKey(t *testing.T, passphrase string) string {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate the key: %v", err)
+	}
+	var block *pem.Block
+	if passphrase != "" {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(key, "test", []byte(passphrase))
+	} else {
+		block, err = ssh.MarshalPrivateKey(key, "test")
+	}
+	if err != nil {
+		t.Fatalf("Failed to marshal the key: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Failed to write the key: %v", err)
+	}
+	return path
+}
+
+func TestAuthenticator_PromptOnlyBlocksItsKey(t *testing.T) {
+	auth := newAuthenticator(nil)
+	prompting, answered := make(chan struct{}), make(chan struct{})
+	// the prompt stays on screen until answered
+	auth.pause = func() func() {
+		close(prompting)
+		<-answered
+		return func() {}
+	}
+
+	encrypted := writeSSHKey(t, "secret")
+	prompted := make(chan error, 1)
+	go func() {
+		_, err := auth.method(&AuthConfig{SSHKey: encrypted}, "git@github.com:acme/api.git")
+		prompted <- err
+	}()
+	<-prompting
+
+	others := make(chan error, 1)
+	go func() {
+		if _, err := auth.method(&AuthConfig{OAuthToken: "token"}, "https://github.com/acme/web.git"); err != nil {
+			others <- err
+			return
+		}
+		_, err := auth.method(&AuthConfig{SSHKey: writeSSHKey(t, "")}, "git@github.com:acme/infra.git")
+		others <- err
+	}()
+	select {
+	case err := <-others:
+		if err != nil {
+			t.Errorf("method() returned an error during the prompt: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("method() waited for the passphrase of another key")
+	}
+
+	close(answered)
+	// stdin isn't a terminal in tests, reading the passphrase fails
+	<-prompted
+}
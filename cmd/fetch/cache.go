@@ -0,0 +1,236 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/progress"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// errCachedFilter is returned for targets set to make partial clones from the cache, which holds full mirrors
+var errCachedFilter = errors.New("cache and filter can't be both set, the cache holds full mirrors")
+
+// mirrorCache holds a mirror of every repository cloned from the cache, so that a repository cloned into
+// several paths is only fetched from its remote once per run. The clones are made, and updated, from the mirror.
+type mirrorCache struct {
+	dir string
+
+	mu      sync.Mutex
+	mirrors map[string]*cachedMirror // keyed by the path of the mirror
+}
+
+// cachedMirror serializes the refreshes of a single mirror
+type cachedMirror struct {
+	mu    sync.Mutex
+	fresh bool // fetched from the remote during this run
+}
+
+// defaultCacheDir is the mirror cache directory of git-intel inside the user's cache directory
+func defaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "git-intel", "mirrors"), nil
+}
+
+// newMirrorCache returns a cache keeping its mirrors in dir, the default cache directory if it's empty.
+// The directory is only created when the first mirror is.
+func newMirrorCache(dir string) (*mirrorCache, error) {
+	if dir == "" {
+		var err error
+		if dir, err = defaultCacheDir(); err != nil {
+			return nil, fmt.Errorf("can't find the mirror cache directory: %w", err)
+		}
+	}
+	// the alternates of the clones point to the mirrors, they have to be absolute
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("can't resolve the mirror cache directory '%s': %w", dir, err)
+	}
+	return &mirrorCache{dir: dir, mirrors: make(map[string]*cachedMirror)}, nil
+}
+
+// path returns where the mirror of a repository lives in the cache: host/owner/name.git.
+// The ssh and https urls of a repository share the same mirror.
+func (c *mirrorCache) path(repoURL string) (string, error) {
+	endpoint, err := transport.NewEndpoint(repoURL)
+	if err != nil {
+		return "", fmt.Errorf("can't parse the url %s: %w", repoURL, err)
+	}
+	host := endpoint.Host
+	if host == "" {
+		host = "local"
+	}
+	// rooted before being cleaned, so that no path escapes the cache
+	name := strings.TrimSuffix(filepath.Clean("/"+endpoint.Path), ".git") + ".git"
+	return filepath.Join(c.dir, host, name), nil
+}
+
+// refresh mirrors a target into the cache, or fetches its mirror if it's already there, at most once per run.
+// Concurrent refreshes of the same mirror wait for each other. It returns the path of the mirror.
+func (c *mirrorCache) refresh(ctx context.Context, out io.Writer, auth *authenticator, target cloneTarget, task *progress.Task) (string, error) {
+	path, err := c.path(target.URL)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	mirror, ok := c.mirrors[path]
+	if !ok {
+		mirror = &cachedMirror{}
+		c.mirrors[path] = mirror
+	}
+	c.mu.Unlock()
+
+	mirror.mu.Lock()
+	defer mirror.mu.Unlock()
+	if mirror.fresh {
+		return path, nil
+	}
+
+	authMethod, err := auth.method(target.Auth, target.URL)
+	if err != nil {
+		return "", fmt.Errorf("error occurred while preparing auth for %s: %w", target.URL, err)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		_, _ = fmt.Fprintf(out, "refreshing the cached mirror %s\n", path)
+		if err := fetchMirror(ctx, path, target.URL, authMethod, task); err != nil {
+			return "", err
+		}
+	} else {
+		_, _ = fmt.Fprintf(out, "mirroring %s into the cache at %s\n", target.URL, path)
+		if err := c.cloneMirror(ctx, path, target, authMethod, task); err != nil {
+			return "", err
+		}
+	}
+
+	mirror.fresh = true
+	return path, nil
+}
+
+// cloneMirror clones a new mirror, staged in the cache directory and moved into place once complete
+func (c *mirrorCache) cloneMirror(ctx context.Context, path string, target cloneTarget, authMethod transport.AuthMethod, task *progress.Task) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("can't create the mirror cache directory '%s': %w", c.dir, err)
+	}
+	staging, err := os.MkdirTemp(c.dir, stagingPrefix+target.Name+"-")
+	if err != nil {
+		return fmt.Errorf("can't create a staging directory for %s: %w", path, err)
+	}
+	defer os.RemoveAll(staging)
+
+	cloneOpts := &git.CloneOptions{URL: target.URL, Auth: authMethod, Mirror: true}
+	if task != nil {
+		task.Watch(packDir(staging, ModeMirror))
+		cloneOpts.Progress = task
+	}
+	if _, err := git.PlainCloneContext(ctx, staging, true, cloneOpts); err != nil {
+		return fmt.Errorf("error occurred while mirroring repo %s: %w", target.URL, err)
+	}
+	if task != nil {
+		task.Watch("")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("can't create the mirror cache directory '%s': %w", filepath.Dir(path), err)
+	}
+	if err := os.Rename(staging, path); err != nil {
+		return fmt.Errorf("error occurred while moving the mirror of %s into place: %w", target.URL, err)
+	}
+	return nil
+}
+
+// fetchMirror fetches every ref of an existing mirror from the url of the target,
+// which can differ from the one the mirror was made from
+func fetchMirror(ctx context.Context, path, repoURL string, authMethod transport.AuthMethod, task *progress.Task) error {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return fmt.Errorf("can't open the cached mirror %s: %w", path, err)
+	}
+
+	fetchOpts := &git.FetchOptions{RemoteURL: repoURL, Auth: authMethod}
+	if task != nil {
+		task.Watch(packDir(path, ModeMirror))
+		defer task.Watch("")
+		fetchOpts.Progress = task
+	}
+	err = repo.FetchContext(ctx, fetchOpts)
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("error occurred while fetching the cached mirror %s: %w", path, err)
+	}
+	return nil
+}
+
+// cloneFromMirror clones a target into dir from its cached mirror, then points origin back to the url of the target.
+// The objects are local, so the clone is never shallow.
+func cloneFromMirror(ctx context.Context, mirror string, target cloneTarget, dir string) error {
+	cloneOpts := goGitCloneOptions(target, nil)
+	cloneOpts.URL = mirror
+	cloneOpts.Depth = 0
+	cloneOpts.Shared = target.Options.Cache.OrDefault() == CacheAlternates
+
+	gitDir, worktree := filepath.Join(dir, git.GitDirName), osfs.New(dir)
+	if target.Options.Mode == ModeBare {
+		gitDir, worktree = dir, nil
+	}
+	repo, err := git.CloneContext(ctx, repoStorage(gitDir), worktree, cloneOpts)
+	if err != nil {
+		return fmt.Errorf("error occurred while cloning repo %s from the cache: %w", target.URL, err)
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return fmt.Errorf("can't read the config of the clone of %s: %w", target.URL, err)
+	}
+	cfg.Remotes[git.DefaultRemoteName].URLs = []string{target.URL}
+	if err := repo.SetConfig(cfg); err != nil {
+		return fmt.Errorf("can't point the clone of %s to its origin: %w", target.URL, err)
+	}
+	return nil
+}
+
+// repoStorage returns the storage of the repository whose git directory is gitDir. Unlike the one git.PlainOpen uses,
+// it reads the objects borrowed from a mirror through absolute alternates, go-git only follows them inside the repository.
+func repoStorage(gitDir string) *filesystem.Storage {
+	return filesystem.NewStorageWithOptions(osfs.New(gitDir), cache.NewObjectLRUDefault(), filesystem.Options{AlternatesFS: osfs.New("/")})
+}
+
+// openRepo opens the repository at dir like git.PlainOpen, along with the objects it borrows from a mirror
+func openRepo(dir string) (*git.Repository, error) {
+	gitDir := filepath.Join(dir, git.GitDirName)
+	var worktree billy.Filesystem = osfs.New(dir)
+	if _, err := os.Stat(gitDir); err != nil {
+		gitDir, worktree = dir, nil
+	}
+	if _, err := os.Stat(filepath.Join(gitDir, "objects", "info", "alternates")); err != nil {
+		return git.PlainOpen(dir)
+	}
+	return git.Open(repoStorage(gitDir), worktree)
+}
+
+// usesCache tells if a target is cloned and updated from the cache. A nil cache is never used.
+func usesCache(mirrors *mirrorCache, target cloneTarget) (bool, error) {
+	o := target.Options
+	if mirrors == nil || !o.Cache.Enabled() {
+		return false, nil
+	}
+	if o.Filter != "" {
+		return false, errCachedFilter
+	}
+	return true, nil
+}
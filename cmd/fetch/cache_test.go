@@ -0,0 +1,129 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/go-git/go-git/v5"
+)
+
+// newTestCache returns a mirror cache in dir, as a new run would
+func newTestCache(t *testing.T, dir string) *mirrorCache {
+	t.Helper()
+	mirrors, err := newMirrorCache(dir)
+	if err != nil {
+		t.Fatalf("Failed to create the mirror cache: %v", err)
+	}
+	return mirrors
+}
+
+// originURL returns the url of the origin remote of the repository at dir
+func originURL(t *testing.T, dir string) string {
+	t.Helper()
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", dir, err)
+	}
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil {
+		t.Fatalf("Failed to read the origin of %s: %v", dir, err)
+	}
+	return remote.Config().URLs[0]
+}
+
+func TestMirrorCache_Path(t *testing.T) {
+	mirrors := &mirrorCache{dir: "/cache"}
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{url: "git@github.com:acme/api.git", expected: "/cache/github.com/acme/api.git"},
+		{url: "https://github.com/acme/api.git", expected: "/cache/github.com/acme/api.git"},
+		{url: "https://github.com/acme/api", expected: "/cache/github.com/acme/api.git"},
+		{url: "https://github.com/../../etc/api", expected: "/cache/github.com/etc/api.git"},
+		{url: "/srv/git/api", expected: "/cache/local/srv/git/api.git"},
+	}
+	for _, tt := range tests {
+		path, err := mirrors.path(tt.url)
+		if err != nil {
+			t.Errorf("path(%q) returned an error: %v", tt.url, err)
+			continue
+		}
+		if path != tt.expected {
+			t.Errorf("path(%q) = %q, expected %q", tt.url, path, tt.expected)
+		}
+	}
+}
+
+func TestCloneRepo_FromCache(t *testing.T) {
+	for _, mode := range []CacheMode{CacheAlternates, CacheCopy} {
+		t.Run(string(mode), func(t *testing.T) {
+			remote := newLocalRemote(t)
+			cacheDir := t.TempDir()
+			mirrors := newTestCache(t, cacheDir)
+			options := &CloneOptions{Cache: mode, Update: UpdatePull}
+			targets := []cloneTarget{
+				{Name: "api", URL: remote, Dir: t.TempDir(), Options: options},
+				{Name: "api", URL: remote, Dir: t.TempDir(), Options: options},
+			}
+
+			var out bytes.Buffer
+			for _, target := range targets {
+				if err := cloneRepo(context.Background(), &out, newAuthenticator(nil), mirrors, target, nil); err != nil {
+					t.Fatalf("cloneRepo() returned an error: %v", err)
+				}
+			}
+			if n := strings.Count(out.String(), "mirroring "); n != 1 {
+				t.Errorf("the remote was mirrored %d times, expected once:\n%s", n, out.String())
+			}
+
+			for _, target := range targets {
+				if url := originURL(t, target.clonePath()); url != remote {
+					t.Errorf("the origin of the clone is %s, expected %s", url, remote)
+				}
+				alternates := filepath.Join(target.clonePath(), git.GitDirName, "objects", "info", "alternates")
+				if _, err := os.Stat(alternates); (err == nil) != (mode == CacheAlternates) {
+					t.Errorf("the clone has alternates: %v", err == nil)
+				}
+				if packs, _ := os.ReadDir(packDir(target.clonePath(), ModeWorktree)); mode == CacheAlternates && len(packs) > 0 {
+					t.Errorf("the clone copied %d pack files instead of borrowing them", len(packs))
+				}
+				if gitAvailable() {
+					if _, err := runGit(context.Background(), target.clonePath(), nil, nil, "fsck", "--connectivity-only"); err != nil {
+						t.Errorf("git can't read the clone: %v", err)
+					}
+				}
+			}
+
+			// a later run refreshes the mirror once, then updates the clones from it
+			commitFile(t, remote, "new.txt", "new\n")
+			mirrors = newTestCache(t, cacheDir)
+			out.Reset()
+			for _, target := range targets {
+				if err := cloneRepo(context.Background(), &out, newAuthenticator(nil), mirrors, target, nil); err != nil {
+					t.Fatalf("cloneRepo() failed to update a clone made from the cache: %v", err)
+				}
+				if _, err := os.Stat(filepath.Join(target.clonePath(), "new.txt")); err != nil {
+					t.Errorf("the update did not bring the new remote commit: %v", err)
+				}
+			}
+			if n := strings.Count(out.String(), "refreshing "); n != 1 {
+				t.Errorf("the mirror was refreshed %d times, expected once:\n%s", n, out.String())
+			}
+		})
+	}
+}
+
+func TestCloneRepo_CacheWithFilter(t *testing.T) {
+	target := cloneTarget{Name: "api", URL: "/nowhere", Dir: t.TempDir(), Options: &CloneOptions{Cache: CacheCopy, Filter: FilterBlobless}}
+	if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), newTestCache(t, t.TempDir()), target, nil); !errors.Is(err, errCachedFilter) {
+		t.Errorf("cloneRepo() returned %v, expected %v", err, errCachedFilter)
+	}
+}
@@ -0,0 +1,87 @@
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/spf13/cobra"
+)
+
+// BuildExplainCmd prints the effective clone options and auth of every configured repo, org and query
+func BuildExplainCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain [filter]",
+		Short: "Explains the effective clone options of the configured repos, orgs and queries",
+		Long: `Explains the effective clone options and auth of every repo, org and search query in the fetch config.
+Options and auth are inherited from the global level to the path, org, query and repo levels, field by field.
+Every value is printed along with the config level it came from.
+The optional filter only explains the repos, orgs and queries whose url, name or query contains it.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			var filter string
+			if len(args) > 0 {
+				filter = args[0]
+			}
+
+			return explainConfig(cmd.OutOrStdout(), config, filter)
+		},
+	}
+}
+
+// explainConfig writes the effective options of every repo, org and query matching the filter
+func explainConfig(out io.Writer, config Config, filter string) error {
+	for _, path := range config.Paths {
+		for i := range path.Repos {
+			repo := &path.Repos[i]
+			if !strings.Contains(repo.Url, filter) {
+				continue
+			}
+			title := fmt.Sprintf("repo %s -> %s", repo.Url, path.Path)
+			if err := writeExplanation(out, title, resolveOptions(layersFor(config, path, nil, repo)...)); err != nil {
+				return err
+			}
+		}
+		for i := range path.Orgs {
+			org := &path.Orgs[i]
+			if !strings.Contains(org.Name, filter) {
+				continue
+			}
+			title := fmt.Sprintf("org %s (all repositories) -> %s", org.Name, path.Path)
+			if err := writeExplanation(out, title, resolveOptions(layersFor(config, path, org, nil)...)); err != nil {
+				return err
+			}
+		}
+		for i := range path.Queries {
+			query := &path.Queries[i]
+			if !strings.Contains(query.Query, filter) {
+				continue
+			}
+			title := fmt.Sprintf("query '%s' (all results) -> %s", query.Query, path.Path)
+			if err := writeExplanation(out, title, resolveOptions(queryLayers(config, path, query)...)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeExplanation(out io.Writer, title string, eff effectiveOptions) error {
+	_, _ = fmt.Fprintln(out, title)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, row := range eff.explain() {
+		_, _ = fmt.Fprintf(w, "  %s\t%s\t(%s)\n", row.Key, row.Value, row.Level)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(out)
+	return nil
+}
@@ -5,46 +5,81 @@ import (
 	"errors"
 	"fmt"
 	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/progress"
+	"github.com/florinutz/git-intel/src/resolve"
 	"github.com/go-git/go-git/v5"
-	git_ssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
-	"github.com/google/go-github/v62/github"
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/agent"
-	"golang.org/x/crypto/ssh/terminal"
-	"net"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
-	"syscall"
+	"time"
 )
 
 // BuildFetchCmd clones repos
 func BuildFetchCmd() (cmd *cobra.Command) {
-	var opts Config
+	var (
+		opts    Config
+		jobs    int
+		plan    bool
+		dryRun  bool
+		output  string
+		verbose bool
+		noCache bool
+	)
 
 	cmd = &cobra.Command{
 		Use:   "fetch",
 		Short: "Fetch github repos, clone them to specified directories",
-		Long: `Fetch multiple github repositories and clone them to specified directories.
-The repositories can be specified by clone URL or by organization.
-The organization repos list can have skipped repos.
-The configuration is read from the config file specified by the --config flag.
-The config file should be in YAML format and have a 'paths' key with a list of paths to clone the repos to.
-Each path should have a 'path' key with the directory to clone the repos to, and either a 'repos' key with a list of clone URLs or an 'org' key with the organization name.
-The 'org' key can also have an 'org_exceptions' key with a list of repos to skip.
+		Long: `Fetch multiple repositories and clone them to specified directories, or update the ones already there.
+The repositories can be specified by clone URL, by owner (an organization, a user, or "@me") or by search query,
+on GitHub, GitLab, Gitea and Forgejo hosts.
+The configuration is read from the 'fetch' key of the config file specified by the --config flag, in YAML.
+Every option is described in docs/fetch-config.md.
+The --plan flag shows which rule included or excluded each repository, --dry-run what would be cloned or updated.
 
 Example config file:
-paths:
-
+fetch:
+  paths:
+    - path: /home/me/work
+      repos:
+        - url: git@github.com:example/repo1.git
+      orgs:
+        - name: exampleOrg
 `,
-		// todo orgs will be in the config file along with everything else
-		Args: cobra.ExactArgs(1), // the org name, which should ve removed once it works
+		Args: cobra.NoArgs,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutputFormat(output); err != nil {
+				return err
+			}
+
+			var err error
+			if opts, err = loadConfig(); err != nil {
+				return err
+			}
+
+			if err := validateOptions(opts.Clone); err != nil {
+				return err
+			}
+
+			if _, err := configHosts(opts); err != nil {
+				return err
+			}
+
+			if _, err := newRetryPolicy(opts.Retry); err != nil {
+				return err
+			}
+
+			if len(opts.Paths) == 0 {
+				return fmt.Errorf("no paths configured, add a 'paths' list under the 'fetch' key of the config file")
+			}
+
 			for _, pathConfig := range opts.Paths {
-				if len(pathConfig.Repos) == 0 && len(pathConfig.Orgs) == 0 {
-					return fmt.Errorf("at least one GitHub URL or an organization is required for each path")
+				if len(pathConfig.Repos) == 0 && len(pathConfig.Orgs) == 0 && len(pathConfig.Queries) == 0 {
+					return fmt.Errorf("at least one GitHub URL, an organization or a search query is required for each path")
 				}
 				for _, repo := range pathConfig.Repos {
 					err := validateRepo(repo.Url)
@@ -57,6 +92,29 @@ paths:
 					if err != nil {
 						return err
 					}
+					if err := validateAffiliation(org); err != nil {
+						return fmt.Errorf("org '%s': %w", org.Name, err)
+					}
+					if err := org.TopicsMatch.Validate(); err != nil {
+						return fmt.Errorf("org '%s': %w", org.Name, err)
+					}
+					if _, err := newRepoFilter(org.RepoFilter); err != nil {
+						return fmt.Errorf("org '%s': %w", org.Name, err)
+					}
+					if _, err := newRepoOrder(org.RepoOrder); err != nil {
+						return fmt.Errorf("org '%s': %w", org.Name, err)
+					}
+					if _, err := newRepoMatcher(org); err != nil {
+						return fmt.Errorf("org '%s': %w", org.Name, err)
+					}
+				}
+				for _, query := range pathConfig.Queries {
+					if query.Query == "" {
+						return fmt.Errorf("path '%s': search query is required", pathConfig.Path)
+					}
+				}
+				if err := validateCloneOptions(pathConfig); err != nil {
+					return err
 				}
 
 				// validate that the directory exists, that it's a directory and that it's writeable:
@@ -68,140 +126,318 @@ paths:
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// todo orgName as an arg once it works (it will be in the config file)
-			orgName := args[0]
-
-			token := os.Getenv("GITHUB_TOKEN")
-			if token == "" {
-				return fmt.Errorf("GITHUB_TOKEN environment variable not set")
-			}
-
-			client := github.NewClient(nil).WithAuthToken(token)
-
-			ghListOpts := &github.RepositoryListByOrgOptions{
-				Type:      RepoTypePrivate.String(),
-				Sort:      RepoListSortUpdated.String(),
-				Direction: "desc",
-				ListOptions: github.ListOptions{
-					PerPage: 100,
-				},
+			hosts, err := configHosts(opts)
+			if err != nil {
+				return err
 			}
-
-			ctx := context.Background()
-			var orgRepos []*github.Repository
-			for {
-				pageRepos, resp, err := client.Repositories.ListByOrg(ctx, orgName, ghListOpts)
-				if err != nil {
-					return fmt.Errorf("error occurred while fetching org repositories: %w", err)
-				}
-				orgRepos = append(orgRepos, pageRepos...)
-				if resp.NextPage == 0 {
-					break
-				}
-				ghListOpts.Page = resp.NextPage
+			policy, err := newRetryPolicy(opts.Retry)
+			if err != nil {
+				return err
 			}
-
-			// Iterate through and print all the names of the organization's repositories
-			fmt.Printf("%-70s %-30s\n", "Repo Name", "Last Updated")
-			for _, repo := range orgRepos {
-				fmt.Printf("%-70s %-30s\n", *repo.Name, repo.UpdatedAt.Format("2006-01-02"))
+			// the progress of the clones, and everything logged while the run goes on, is shown on stderr
+			display := progress.New(cmd.ErrOrStderr(), 0)
+			defer display.Close()
+			transport := newAPITransport(noCache, policy, display)
+			if verbose {
+				defer transport.report(cmd.ErrOrStderr())
 			}
-
-			if len(orgRepos) == 0 {
-				return nil
+			resolver, err := newResolver(hosts, transport)
+			if err != nil {
+				return err
 			}
 
-			// clone the first 1 repo:
-			repo := orgRepos[0]
-
-			conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+			ctx := cmd.Context()
+			targets, decisions, err := collectTargets(ctx, resolver, opts)
 			if err != nil {
-				return fmt.Errorf("dialing the ssh agent failed: %w", err)
+				return err
 			}
-			defer conn.Close()
 
-			sshAgent := agent.NewClient(conn)
+			if plan {
+				return writeSelections(cmd.OutOrStdout(), output, decisions)
+			}
 
-			auth := &git_ssh.PublicKeysCallback{
-				User: "git",
-				Callback: func() ([]ssh.Signer, error) {
-					signers, err := sshAgent.Signers()
-					if err != nil {
-						return nil, fmt.Errorf("error occurred while getting ssh agent signers: %w", err)
-					}
+			auth := newAuthenticator(hosts)
+			auth.pause = display.Pause
+			defer auth.Close()
 
-					// todo this single key signer also works, but let's have it disabled till the configs work and we can use it as a configurable method alongside the agent
+			if dryRun {
+				return writePlan(cmd.OutOrStdout(), output, buildPlan(auth, targets))
+			}
 
-					//keySigner, err := getSSHKeySigner(os.Getenv("HOME") + "/.ssh/id_ed25519")
-					//if err != nil {
-					//	return nil, fmt.Errorf("error occurred while getting ssh key signer: %w", err)
-					//}
-					//signers := []ssh.Signer{keySigner}
+			mirrors, err := newMirrorCache(opts.CacheDir)
+			if err != nil {
+				return err
+			}
 
-					return signers, nil
-				},
+			var paths []string
+			for _, path := range opts.Paths {
+				paths = append(paths, path.Path)
+			}
+			if _, err := os.Stat(mirrors.dir); err == nil {
+				paths = append(paths, mirrors.dir)
+			}
+			if err := cleanStaleStaging(display, paths); err != nil {
+				return err
 			}
 
-			clonePath := filepath.Join("repos", *repo.Name)
-			if _, err := git.PlainCloneContext(ctx, clonePath, false, &git.CloneOptions{
-				URL:   *repo.SSHURL,
-				Depth: 1,
-				Auth:  auth,
-			}); err != nil {
-				return fmt.Errorf("error occurred while cloning repo: %w", err)
+			if !cmd.Flags().Changed("jobs") && opts.Concurrency > 0 {
+				jobs = opts.Concurrency
 			}
 
-			return nil
+			results := cloneAll(ctx, display, auth, mirrors, policy, targets, jobs)
+			display.Close()
+
+			return report(cmd.OutOrStdout(), results, transport.retrier.Retries())
 		},
 	}
 
-	viper.UnmarshalKey("paths", &opts)
+	cmd.Flags().BoolVar(&plan, "plan", false, "print which rule included or excluded each repository, without cloning anything")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print what would be cloned or updated, and how, without touching the disk")
+	cmd.Flags().StringVarP(&output, "output", "o", outputTable, "output format of --plan and --dry-run: table or json")
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", defaultJobs, "number of repositories cloned in parallel, overrides the concurrency config")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "print the http cache statistics and the remaining API rate limits when done")
+	cmd.Flags().BoolVar(&noCache, "no-cache", false, "call the APIs without the on-disk http cache")
 
 	return
 }
 
-// getSSHKeySigner reads an SSH key from the given path and returns a signer
-// todo use this when the configs work and we can use it as a configurable method alongside the agent
-func getSSHKeySigner(sshKeyPath string) (ssh.Signer, error) {
-	sshKey, err := os.ReadFile(sshKeyPath)
+// loadConfig reads the fetch configuration from the 'fetch' key of the config file
+func loadConfig() (Config, error) {
+	var config Config
+	hooks := mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		timeToStringHook,
+	)
+	decodeHook := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(configKeysHook(hooks), hooks))
+	if err := viper.UnmarshalKey("fetch", &config, decodeHook); err != nil {
+		return config, fmt.Errorf("failed to read the fetch config: %w", err)
+	}
+	return config, nil
+}
+
+// timeToStringHook turns the dates YAML parses on its own, like updated_after: 2024-01-01, back into strings
+func timeToStringHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	t, ok := data.(time.Time)
+	if !ok || to.Kind() != reflect.String {
+		return data, nil
+	}
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return t.Format(time.DateOnly), nil
+	}
+	return t.Format(time.RFC3339), nil
+}
+
+// keyedConfigs are the config types recording the keys set on them in their Keys
+var keyedConfigs = map[reflect.Type]bool{
+	reflect.TypeOf(CloneOptions{}): true,
+	reflect.TypeOf(AuthConfig{}):   true,
+}
+
+// configKeysHook decodes the clone options and the auth with the given hooks and records the keys they set in their Keys,
+// the zero values being indistinguishable from the unset ones once decoded
+func configKeysHook(hooks mapstructure.DecodeHookFunc) mapstructure.DecodeHookFuncType {
+	var hook mapstructure.DecodeHookFuncType
+	hook = func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		fields := reflect.ValueOf(data)
+		if !keyedConfigs[to] || fields.Kind() != reflect.Map {
+			return data, nil
+		}
+
+		// the auth nested in the clone options records its keys too, while the value itself is decoded as it is
+		nested := func(from, nestedTo reflect.Type, data interface{}) (interface{}, error) {
+			if nestedTo == to {
+				return data, nil
+			}
+			return hook(from, nestedTo, data)
+		}
+		o := reflect.New(to)
+		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			DecodeHook:       mapstructure.ComposeDecodeHookFunc(nested, hooks),
+			WeaklyTypedInput: true,
+			Result:           o.Interface(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := decoder.Decode(data); err != nil {
+			return nil, err
+		}
+		keys := make(map[string]bool, fields.Len())
+		for _, key := range fields.MapKeys() {
+			keys[strings.ToLower(fmt.Sprint(key.Interface()))] = true
+		}
+		o.Elem().FieldByName("Keys").Set(reflect.ValueOf(keys))
+		return o.Elem().Interface(), nil
+	}
+	return hook
+}
+
+// cloneRepo clones a single target into its path, or updates it if it's already there.
+// Targets using the cache are cloned from their mirror in mirrors, which is refreshed first.
+// The progress of the transfer is reported to task, unless it's nil.
+func cloneRepo(ctx context.Context, out io.Writer, auth *authenticator, mirrors *mirrorCache, target cloneTarget, task *progress.Task) error {
+	if _, err := os.Stat(target.clonePath()); err == nil {
+		return updateRepo(ctx, out, auth, mirrors, target, task)
+	}
+
+	cached, err := usesCache(mirrors, target)
 	if err != nil {
-		return nil, fmt.Errorf("error occurred while reading ssh key at %s: %w", sshKeyPath, err)
+		return err
+	}
+	var mirror string
+	if cached {
+		if mirror, err = mirrors.refresh(ctx, out, auth, target, task); err != nil {
+			return err
+		}
 	}
 
-	signer, err := ssh.ParsePrivateKey(sshKey)
+	_, _ = fmt.Fprintf(out, "cloning %s into %s\n", target.URL, target.clonePath())
+	staging, err := newStagingDir(target)
 	if err != nil {
-		var passphraseMissingError *ssh.PassphraseMissingError
-		needsPassphrase := errors.As(err, &passphraseMissingError)
-		if !needsPassphrase {
-			return nil, fmt.Errorf("parsing private key failed: %w", err)
+		return err
+	}
+	// a failed or interrupted clone never reaches the clone path, the next run starts it over
+	defer os.RemoveAll(staging)
+
+	if task != nil && !cached {
+		task.Watch(packDir(staging, target.Options.Mode))
+	}
+	if err := cloneInto(ctx, auth, target, mirror, staging, task); err != nil {
+		return err
+	}
+	if task != nil {
+		// measured before the staging directory moves away
+		task.Watch("")
+	}
+
+	// the clone is kept when only some of its submodules failed, they are reported along with it
+	var submodulesErr error
+	if target.Options.Recurse && !target.Options.Mode.IsBare() {
+		submodulesErr = updateSubmodules(ctx, auth, target, staging)
+		var failures *submoduleError
+		if submodulesErr != nil && !errors.As(submodulesErr, &failures) {
+			return submodulesErr
 		}
+	}
+
+	if err := os.Rename(staging, target.clonePath()); err != nil {
+		return fmt.Errorf("error occurred while moving the clone of %s into place: %w", target.URL, err)
+	}
+
+	return submodulesErr
+}
 
-		fmt.Print("Enter passphrase for encrypted key: ")
-		passphraseBytes, err := terminal.ReadPassword(int(syscall.Stdin))
+// cloneInto clones a target into dir as its mode tells: from its cached mirror when there's one,
+// with go-git, or with the git binary for partial clones
+func cloneInto(ctx context.Context, auth *authenticator, target cloneTarget, mirror, dir string, task *progress.Task) error {
+	o := target.Options
+	if mirror != "" {
+		if err := cloneFromMirror(ctx, mirror, target, dir); err != nil {
+			return err
+		}
+	} else if o.Filter != "" {
+		env, err := auth.gitEnv(target.Auth, target.URL)
+		if err != nil {
+			return fmt.Errorf("error occurred while preparing auth for %s: %w", target.URL, err)
+		}
+		if err := cloneWithGit(ctx, env, target, dir, task); err != nil {
+			return fmt.Errorf("error occurred while cloning repo %s: %w", target.URL, err)
+		}
+	} else {
+		authMethod, err := auth.method(target.Auth, target.URL)
 		if err != nil {
-			return nil, fmt.Errorf("read passphrase error: %w", err)
+			return fmt.Errorf("error occurred while preparing auth for %s: %w", target.URL, err)
 		}
-		passphrase := strings.TrimSpace(string(passphraseBytes))
-		fmt.Println()
+		cloneOpts := goGitCloneOptions(target, authMethod)
+		if task != nil {
+			cloneOpts.Progress = task
+		}
+		if _, err := git.PlainCloneContext(ctx, dir, o.Mode == ModeBare, cloneOpts); err != nil {
+			return fmt.Errorf("error occurred while cloning repo %s: %w", target.URL, err)
+		}
+	}
+
+	// go-git fetches the branches of bare clones as remote branches, git keeps them as local ones
+	bareWithRemoteBranches := o.Mode == ModeBare && o.Filter == ""
+	if o.Mode == ModeMirror || !(o.AllBranches || bareWithRemoteBranches) {
+		return nil
+	}
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return fmt.Errorf("can't open the clone of %s: %w", target.URL, err)
+	}
+	return syncLocalBranches(repo, false)
+}
+
+// validateCloneOptions checks the clone options of a path and of its repos, orgs and queries
+func validateCloneOptions(path Path) error {
+	candidates := []*CloneOptions{path.CloneOptions}
+	for _, repo := range path.Repos {
+		candidates = append(candidates, repo.RepoCloneOptions)
+	}
+	for _, org := range path.Orgs {
+		candidates = append(candidates, org.OrgCloneOptions)
+	}
+	for _, query := range path.Queries {
+		candidates = append(candidates, query.QueryCloneOptions)
+	}
+
+	for _, o := range candidates {
+		if err := validateOptions(o); err != nil {
+			return fmt.Errorf("path '%s': %w", path.Path, err)
+		}
+	}
+	return nil
+}
 
-		signer, err = ssh.ParsePrivateKeyWithPassphrase(sshKey, []byte(passphrase))
+// validateOptions checks a single set of clone options, nil being valid
+func validateOptions(o *CloneOptions) error {
+	if o == nil {
+		return nil
+	}
+	for _, err := range []error{o.Update.Validate(), o.Mode.Validate(), o.Filter.Validate(), o.Tags.Validate(), o.Cache.Validate()} {
 		if err != nil {
-			return nil, fmt.Errorf("parsing private key with passphrase failed: %w", err)
+			return err
 		}
 	}
-	return signer, nil
+	if o.SingleBranch && o.AllBranches {
+		return fmt.Errorf("single_branch and all_branches can't be both set")
+	}
+	if o.Filter != "" && o.Cache.Enabled() {
+		return errCachedFilter
+	}
+	if o.Recurse && o.Mode.IsBare() {
+		return fmt.Errorf("recurse needs a worktree to check the submodules out, it can't be set along with the %s mode", o.Mode)
+	}
+	if o.Filter != "" && !gitAvailable() {
+		return fmt.Errorf("the %s filter needs the git binary, which is not in the PATH", o.Filter)
+	}
+	return nil
 }
 
 func validateRepo(repoUrl string) error {
-	// your validation logic for repo URL goes here
+	if repoUrl == "" {
+		return fmt.Errorf("repo url is required")
+	}
 	return nil
 }
 
 func validateOrg(orgName string) error {
-	// your validation logic for orgName goes here
+	if orgName == "" {
+		return fmt.Errorf("org name is required")
+	}
 	return nil
 }
 
+// validateAffiliation checks the affiliation of an org entry, which only "@me" can have
+func validateAffiliation(org GithubOrgConfig) error {
+	if len(org.Affiliation) > 0 && org.Name != resolve.Me {
+		return fmt.Errorf("affiliation only applies to %s", resolve.Me)
+	}
+	return resolve.ValidateAffiliation(org.Affiliation)
+}
+
 // validateTargetPath validates a path as a valid candidate for hosting the cloned git repos
 func validateTargetPath(path string) error {
 	if path == "" {
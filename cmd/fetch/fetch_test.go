@@ -5,7 +5,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/spf13/viper"
 )
 
 func TestValidateTargetPath_ValidPath(t *testing.T) {
@@ -78,3 +84,170 @@ func TestValidateTargetPath_StatError(t *testing.T) {
 		t.Errorf("validateTargetPath() returned an unexpected error for an invalid path: %v", err)
 	}
 }
+
+func TestValidateAffiliation(t *testing.T) {
+	valid := []GithubOrgConfig{
+		{Name: "@me"},
+		{Name: "@me", Affiliation: []string{"owner", "organization_member"}},
+		{Name: "acme"},
+	}
+	for _, org := range valid {
+		if err := validateAffiliation(org); err != nil {
+			t.Errorf("validateAffiliation() returned an error for %+v: %v", org, err)
+		}
+	}
+
+	invalid := []GithubOrgConfig{
+		{Name: "@me", Affiliation: []string{"member"}},
+		{Name: "acme", Affiliation: []string{"owner"}},
+	}
+	for _, org := range invalid {
+		if err := validateAffiliation(org); err == nil {
+			t.Errorf("validateAffiliation() accepted %+v", org)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	jitter := 0.5
+	tests := []struct {
+		name     string
+		yaml     string
+		expected Config
+		err      bool
+	}{
+		{
+			name: "repos and orgs",
+			yaml: `
+fetch:
+  global_clone_options:
+    depth: 1
+  paths:
+    - path: /work
+      repos:
+        - url: git@github.com:acme/api.git
+          repo_clone_options:
+            branch: main
+      orgs:
+        - name: acme
+          exclude_repos: [legacy-*]
+`,
+			expected: Config{
+				Clone: &CloneOptions{Depth: 1, Keys: map[string]bool{"depth": true}},
+				Paths: []Path{{
+					Path:  "/work",
+					Repos: []RepoConfig{{Url: "git@github.com:acme/api.git", RepoCloneOptions: &CloneOptions{Branch: "main", Keys: map[string]bool{"branch": true}}}},
+					Orgs:  []GithubOrgConfig{{Name: "acme", ExcludeRepos: []string{"legacy-*"}}},
+				}},
+			},
+		},
+		{
+			name: "clone options set to zero values",
+			yaml: `
+fetch:
+  global_clone_options:
+    recurse: true
+  paths:
+    - path: /work
+      path_clone_options:
+        recurse: false
+        depth: 0
+        auth:
+          ssh_key: /keys/work
+`,
+			expected: Config{
+				Clone: &CloneOptions{Recurse: true, Keys: map[string]bool{"recurse": true}},
+				Paths: []Path{{
+					Path: "/work",
+					CloneOptions: &CloneOptions{
+						Auth: &AuthConfig{SSHKey: "/keys/work", Keys: map[string]bool{"ssh_key": true}},
+						Keys: map[string]bool{"recurse": true, "depth": true, "auth": true},
+					},
+				}},
+			},
+		},
+		{
+			name: "auth set to zero values",
+			yaml: `
+fetch:
+  auth:
+    oauth_token: secret
+  paths:
+    - path: /work
+      repos:
+        - url: https://github.com/acme/api.git
+          auth:
+            username: bob
+            oauth_token: ""
+`,
+			expected: Config{
+				Auth: &AuthConfig{OAuthToken: "secret", Keys: map[string]bool{"oauth_token": true}},
+				Paths: []Path{{
+					Path:  "/work",
+					Repos: []RepoConfig{{Url: "https://github.com/acme/api.git", Auth: &AuthConfig{Username: "bob", Keys: map[string]bool{"username": true, "oauth_token": true}}}},
+				}},
+			},
+		},
+		{
+			name: "durations and comma separated lists",
+			yaml: `
+fetch:
+  retry:
+    base_delay: 2s
+    max_delay: 1m
+    jitter: 0.5
+    retry_on: network,server
+`,
+			expected: Config{Retry: &RetryConfig{BaseDelay: 2 * time.Second, MaxDelay: time.Minute, Jitter: &jitter, RetryOn: []string{"network", "server"}}},
+		},
+		{
+			name: "dates",
+			yaml: `
+fetch:
+  paths:
+    - path: /work
+      orgs:
+        - name: acme
+          repo_filter:
+            updated_after: 2024-01-01
+            updated_before: 2024-06-01T12:00:00Z
+`,
+			expected: Config{Paths: []Path{{
+				Path: "/work",
+				Orgs: []GithubOrgConfig{{Name: "acme", RepoFilter: &RepoFilterConfig{UpdatedAfter: "2024-01-01", UpdatedBefore: "2024-06-01T12:00:00Z"}}},
+			}}},
+		},
+		{
+			name: "invalid value",
+			yaml: `
+fetch:
+  concurrency: many
+`,
+			err: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			viper.SetConfigType("yaml")
+			if err := viper.ReadConfig(strings.NewReader(tt.yaml)); err != nil {
+				t.Fatalf("Failed to read the config: %v", err)
+			}
+
+			config, err := loadConfig()
+			if tt.err {
+				if err == nil {
+					t.Errorf("loadConfig() = %+v, expected an error", config)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadConfig() returned an error: %v", err)
+			}
+			if !reflect.DeepEqual(config, tt.expected) {
+				t.Errorf("loadConfig() = %+v, expected %+v", config, tt.expected)
+			}
+		})
+	}
+}
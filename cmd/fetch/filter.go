@@ -0,0 +1,98 @@
+package fetch
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/resolve"
+)
+
+// filterDateLayouts are the accepted formats for the filter dates
+var filterDateLayouts = []string{time.DateOnly, time.RFC3339}
+
+// repoFilter is a RepoFilterConfig with its dates parsed
+type repoFilter struct {
+	*RepoFilterConfig
+	updatedAfter  time.Time
+	updatedBefore time.Time
+}
+
+// newRepoFilter validates the filter config. A nil config gives a filter that keeps everything.
+func newRepoFilter(config *RepoFilterConfig) (*repoFilter, error) {
+	if config == nil {
+		return &repoFilter{RepoFilterConfig: &RepoFilterConfig{}}, nil
+	}
+
+	f := &repoFilter{RepoFilterConfig: config}
+	var err error
+	if f.updatedAfter, err = parseFilterDate(config.UpdatedAfter); err != nil {
+		return nil, fmt.Errorf("invalid updated_after: %w", err)
+	}
+	if f.updatedBefore, err = parseFilterDate(config.UpdatedBefore); err != nil {
+		return nil, fmt.Errorf("invalid updated_before: %w", err)
+	}
+	for _, v := range config.Visibility {
+		if v != "public" && v != "private" && v != "internal" {
+			return nil, fmt.Errorf("invalid visibility '%s', valid values are public, private and internal", v)
+		}
+	}
+
+	return f, nil
+}
+
+func parseFilterDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range filterDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("'%s' is neither a YYYY-MM-DD date nor an RFC3339 timestamp", value)
+}
+
+// reject returns the reason the repository is filtered out, or an empty string if it's kept
+func (f *repoFilter) reject(repo *resolve.Repository) string {
+	switch {
+	case repo.Forks < f.Forks:
+		return fmt.Sprintf("has %d forks, less than %d", repo.Forks, f.Forks)
+	case repo.Stars < f.Stars:
+		return fmt.Sprintf("has %d stars, less than %d", repo.Stars, f.Stars)
+	case !f.updatedAfter.IsZero() && !repo.UpdatedAt.After(f.updatedAfter):
+		return fmt.Sprintf("not updated after %s", f.UpdatedAfter)
+	case !f.updatedBefore.IsZero() && !repo.UpdatedAt.Before(f.updatedBefore):
+		return fmt.Sprintf("not updated before %s", f.UpdatedBefore)
+	case len(f.IncludeLanguages) > 0 && !containsFold(f.IncludeLanguages, repo.Language):
+		return fmt.Sprintf("language '%s' is not included", repo.Language)
+	case containsFold(f.ExcludeLanguages, repo.Language):
+		return fmt.Sprintf("language '%s' is excluded", repo.Language)
+	case f.Archived != nil && *f.Archived != repo.Archived:
+		return fmt.Sprintf("archived is %t", repo.Archived)
+	case f.Fork != nil && *f.Fork != repo.Fork:
+		return fmt.Sprintf("fork is %t", repo.Fork)
+	case f.Template != nil && *f.Template != repo.Template:
+		return fmt.Sprintf("template is %t", repo.Template)
+	case len(f.Visibility) > 0 && !containsFold(f.Visibility, repo.Visibility):
+		return fmt.Sprintf("visibility '%s' is not included", repo.Visibility)
+	case len(f.Topics) > 0 && !slices.ContainsFunc(repo.Topics, func(t string) bool { return containsFold(f.Topics, t) }):
+		return "has none of the required topics"
+	case slices.ContainsFunc(repo.Topics, func(t string) bool { return containsFold(f.ExcludeTopics, t) }):
+		return "has an excluded topic"
+	case f.MinSize > 0 && repo.Size < f.MinSize:
+		return fmt.Sprintf("size %dKB is under %dKB", repo.Size, f.MinSize)
+	case f.MaxSize > 0 && repo.Size > f.MaxSize:
+		return fmt.Sprintf("size %dKB is over %dKB", repo.Size, f.MaxSize)
+	case len(f.DefaultBranches) > 0 && !slices.Contains(f.DefaultBranches, repo.DefaultBranch):
+		return fmt.Sprintf("default branch '%s' is not included", repo.DefaultBranch)
+	}
+	return ""
+}
+
+// containsFold tells if the list contains the value, ignoring case
+func containsFold(list []string, value string) bool {
+	return slices.ContainsFunc(list, func(s string) bool { return strings.EqualFold(s, value) })
+}
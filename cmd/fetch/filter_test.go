@@ -0,0 +1,72 @@
+package fetch
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/resolve"
+)
+
+func TestRepoFilter_Reject(t *testing.T) {
+	repo := &resolve.Repository{
+		Name:          "service",
+		Forks:         3,
+		Stars:         10,
+		UpdatedAt:     time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		Language:      "Go",
+		Archived:      false,
+		Fork:          true,
+		Visibility:    "private",
+		Topics:        []string{"backend", "payments"},
+		Size:          2048,
+		DefaultBranch: "main",
+	}
+
+	tests := []struct {
+		name   string
+		config *RepoFilterConfig
+		kept   bool
+	}{
+		{"no filter", nil, true},
+		{"enough forks", &RepoFilterConfig{Forks: 3}, true},
+		{"too few stars", &RepoFilterConfig{Stars: 11}, false},
+		{"updated after", &RepoFilterConfig{UpdatedAfter: "2024-01-01"}, true},
+		{"not updated before", &RepoFilterConfig{UpdatedBefore: "2024-01-01T00:00:00Z"}, false},
+		{"included language ignores case", &RepoFilterConfig{IncludeLanguages: []string{"go"}}, true},
+		{"excluded language", &RepoFilterConfig{ExcludeLanguages: []string{"Go"}}, false},
+		{"no archived", &RepoFilterConfig{Archived: ptr(false)}, true},
+		{"no forks", &RepoFilterConfig{Fork: ptr(false)}, false},
+		{"only templates", &RepoFilterConfig{Template: ptr(true)}, false},
+		{"public only", &RepoFilterConfig{Visibility: []string{"public"}}, false},
+		{"any topic", &RepoFilterConfig{Topics: []string{"frontend", "payments"}}, true},
+		{"excluded topic", &RepoFilterConfig{ExcludeTopics: []string{"backend"}}, false},
+		{"too big", &RepoFilterConfig{MaxSize: 1024}, false},
+		{"big enough", &RepoFilterConfig{MinSize: 1024}, true},
+		{"default branch", &RepoFilterConfig{DefaultBranches: []string{"master"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newRepoFilter(tt.config)
+			if err != nil {
+				t.Fatalf("newRepoFilter() returned an error: %v", err)
+			}
+			if reason := f.reject(repo); (reason == "") != tt.kept {
+				t.Errorf("reject() = %q, expected the repo to be kept: %t", reason, tt.kept)
+			}
+		})
+	}
+}
+
+func TestNewRepoFilter_InvalidConfig(t *testing.T) {
+	for _, config := range []*RepoFilterConfig{
+		{UpdatedAfter: "last week"},
+		{UpdatedBefore: "2024-13-01"},
+		{Visibility: []string{"secret"}},
+	} {
+		if _, err := newRepoFilter(config); err == nil {
+			t.Errorf("newRepoFilter(%+v) did not return an error", config)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/progress"
+	"github.com/florinutz/git-intel/src/retry"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Partial clones are made and updated by the git binary: go-git can't fetch with a filter,
+// nor read the objects a partial clone fetches lazily.
+
+// gitAvailable tells if the git binary can be run
+func gitAvailable() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+// runGit runs the git binary in dir, with env added to the environment, and returns its output.
+// Its progress is reported to task, unless it's nil. Failures carry the last line git printed, and all of its stderr
+// in a *retry.GitError.
+func runGit(ctx context.Context, dir string, env []string, task *progress.Task, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), env...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if task != nil {
+		cmd.Stderr = io.MultiWriter(&stderr, task)
+	}
+
+	if err := cmd.Run(); err != nil {
+		// the retry policy tells the transient failures from what git printed
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+			err = fmt.Errorf("git %s: %s: %w", args[0], last, err)
+		} else {
+			err = fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return "", &retry.GitError{Err: err, Stderr: stderr.String()}
+	}
+	return stdout.String(), nil
+}
+
+// cloneWithGit makes a partial clone of a target into dir
+func cloneWithGit(ctx context.Context, env []string, target cloneTarget, dir string, task *progress.Task) error {
+	o := target.Options
+	args := []string{"clone", "--progress", "--filter=" + string(o.Filter)}
+	switch o.Mode {
+	case ModeBare:
+		args = append(args, "--bare")
+	case ModeMirror:
+		args = append(args, "--mirror")
+	}
+	if o.Depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(o.Depth))
+	}
+	if o.Mode != ModeMirror {
+		if o.Branch != "" {
+			args = append(args, "--branch", o.Branch)
+		}
+		if singleBranch(o) {
+			args = append(args, "--single-branch")
+		} else {
+			args = append(args, "--no-single-branch")
+		}
+		switch o.Tags.OrDefault() {
+		case TagsNone:
+			args = append(args, "--no-tags")
+		case TagsAll:
+			args = append(args, "--config", "remote.origin.tagOpt=--tags")
+		}
+	}
+	args = append(args, "--", target.URL, dir)
+
+	_, err := runGit(ctx, "", env, task, args...)
+	return err
+}
+
+// updateWithGit fetches a partial clone and applies the update strategy of its target to it
+func updateWithGit(ctx context.Context, out io.Writer, env []string, target cloneTarget, strategy UpdateStrategy, task *progress.Task) error {
+	o, dir := target.Options, target.clonePath()
+
+	args := []string{"fetch", "--progress", git.DefaultRemoteName}
+	if o.Mode == ModeBare {
+		// bare clones have no remote-tracking branches, their own branches follow the remote
+		refspec := "+refs/heads/*:refs/heads/*"
+		if singleBranch(o) {
+			branch, err := runGit(ctx, dir, env, nil, "symbolic-ref", "--short", "HEAD")
+			if err != nil {
+				return err
+			}
+			branch = strings.TrimSpace(branch)
+			refspec = "+refs/heads/" + branch + ":refs/heads/" + branch
+		}
+		args = append(args, refspec)
+	}
+	if _, err := runGit(ctx, dir, env, task, args...); err != nil {
+		return fmt.Errorf("error occurred while fetching %s: %w", dir, err)
+	}
+	if o.Mode.IsBare() {
+		return nil
+	}
+
+	if o.AllBranches {
+		repo, err := git.PlainOpen(dir)
+		if err != nil {
+			return fmt.Errorf("can't open existing repo %s: %w", dir, err)
+		}
+		if err := syncLocalBranches(repo, false); err != nil {
+			return err
+		}
+	}
+
+	switch strategy {
+	case UpdatePull:
+		_, err := moveToRemoteWithGit(ctx, env, dir, o.Branch, false)
+		return err
+	case UpdateResetToRemote:
+		backup, err := moveToRemoteWithGit(ctx, env, dir, o.Branch, true)
+		reportBackup(out, target, backup)
+		return err
+	}
+	return nil
+}
+
+// moveToRemoteWithGit is moveToRemote for partial clones, checking out the missing objects from the remote
+func moveToRemoteWithGit(ctx context.Context, env []string, dir, branch string, reset bool) (string, error) {
+	status, err := runGit(ctx, dir, env, nil, "status", "--porcelain")
+	if err != nil {
+		return "", fmt.Errorf("can't get the worktree status: %w", err)
+	}
+	if strings.TrimSpace(status) != "" {
+		return "", errDirtyWorktree
+	}
+
+	head, err := runGit(ctx, dir, env, nil, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return "", errDetachedHead
+	}
+	if branch == "" {
+		branch = strings.TrimSpace(head)
+	}
+
+	remoteRef := remoteBranchPrefix + branch
+	if _, err := runGit(ctx, dir, env, nil, "rev-parse", "--verify", "--quiet", remoteRef); err != nil {
+		return "", fmt.Errorf("can't find the remote branch %s/%s: %w", git.DefaultRemoteName, branch, err)
+	}
+
+	var backup string
+	localRef := "refs/heads/" + branch
+	if local, err := runGit(ctx, dir, env, nil, "rev-parse", "--verify", "--quiet", localRef); err == nil {
+		_, err := runGit(ctx, dir, env, nil, "merge-base", "--is-ancestor", localRef, remoteRef)
+		var exitErr *exec.ExitError
+		switch {
+		case errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && reset:
+			backup = backupBranch(branch, plumbing.NewHash(strings.TrimSpace(local)))
+			if _, err := runGit(ctx, dir, env, nil, "branch", "--force", backup, localRef); err != nil {
+				return "", fmt.Errorf("can't back up the local commits of %s: %w", branch, err)
+			}
+		case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+			return "", fmt.Errorf("branch %s: %w", branch, errDiverged)
+		case err != nil:
+			return "", fmt.Errorf("can't compare %s to %s: %w", localRef, remoteRef, err)
+		}
+	}
+
+	// the local branch is either missing, behind or backed up, moving it loses nothing
+	_, err = runGit(ctx, dir, env, nil, "checkout", "--quiet", "-B", branch, remoteRef)
+	return backup, err
+}
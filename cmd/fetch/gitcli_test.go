@@ -0,0 +1,191 @@
+package fetch
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/retry"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// newFilterRemote creates a remote serving partial clones, returning its file:// url.
+// Local paths are cloned by copying the objects, the filters only apply to file:// urls.
+func newFilterRemote(t *testing.T) (dir, url string) {
+	t.Helper()
+	if !gitAvailable() {
+		t.Skip("the git binary is not in the PATH")
+	}
+
+	dir = newBranchedRemote(t)
+	if _, err := runGit(context.Background(), dir, nil, nil, "config", "uploadpack.allowFilter", "true"); err != nil {
+		t.Fatalf("Failed to allow filters on the remote: %v", err)
+	}
+	return dir, "file://" + dir
+}
+
+// gitConfig returns a config value of the repository at dir
+func gitConfig(t *testing.T, dir, key string) string {
+	t.Helper()
+	value, err := runGit(context.Background(), dir, nil, nil, "config", "--get", key)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", key, err)
+	}
+	return strings.TrimSpace(value)
+}
+
+func TestCloneRepo_PartialClones(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  CloneOptions
+		worktree bool
+	}{
+		{name: "blob-less worktree", options: CloneOptions{Mode: ModeWorktree, Filter: FilterBlobless}, worktree: true},
+		{name: "tree-less bare", options: CloneOptions{Mode: ModeBare, Filter: FilterTreeless}},
+		{name: "blob-less mirror", options: CloneOptions{Mode: ModeMirror, Filter: FilterBlobless}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, url := newFilterRemote(t)
+			target := cloneTarget{Name: "api", URL: url, Dir: t.TempDir(), Options: &tt.options}
+
+			if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+				t.Fatalf("cloneRepo() returned an error: %v", err)
+			}
+
+			if filter := gitConfig(t, target.clonePath(), "remote.origin.partialclonefilter"); filter != string(tt.options.Filter) {
+				t.Errorf("the clone has the filter %q, expected %q", filter, tt.options.Filter)
+			}
+			if bare := gitConfig(t, target.clonePath(), "core.bare"); (bare == "false") != tt.worktree {
+				t.Errorf("the clone has core.bare = %s", bare)
+			}
+			if _, err := os.Stat(filepath.Join(target.clonePath(), "README.md")); tt.worktree != (err == nil) {
+				t.Errorf("the clone has a worktree: %v, expected %v", err == nil, tt.worktree)
+			}
+			if tt.options.Mode == ModeBare && !hasRef(t, target.clonePath(), "refs/heads/feature") {
+				t.Errorf("the bare clone is missing the feature branch")
+			}
+		})
+	}
+}
+
+func TestUpdateRepo_PartialClone(t *testing.T) {
+	remote, url := newFilterRemote(t)
+	target := cloneTarget{Name: "api", URL: url, Dir: t.TempDir(), Options: &CloneOptions{Filter: FilterBlobless, Update: UpdatePull}}
+	if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+		t.Fatalf("Failed to clone the remote: %v", err)
+	}
+	commitFile(t, remote, "new.txt", "new\n")
+
+	if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+		t.Fatalf("cloneRepo() failed to update the partial clone: %v", err)
+	}
+	if content, err := os.ReadFile(filepath.Join(target.clonePath(), "new.txt")); err != nil || string(content) != "new\n" {
+		t.Errorf("the pull did not bring the new remote commit: %q, %v", content, err)
+	}
+
+	commitFile(t, remote, "newer.txt", "newer\n")
+	readme := filepath.Join(target.clonePath(), "README.md")
+	if err := os.WriteFile(readme, []byte("local change\n"), 0644); err != nil {
+		t.Fatalf("Failed to change the clone: %v", err)
+	}
+	if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); !errors.Is(err, errDirtyWorktree) {
+		t.Errorf("cloneRepo() returned %v for a dirty partial clone, expected %v", err, errDirtyWorktree)
+	}
+}
+
+func TestUpdateRepo_PartialCloneResetDiverged(t *testing.T) {
+	remote, url := newFilterRemote(t)
+	target := cloneTarget{Name: "api", URL: url, Dir: t.TempDir(), Options: &CloneOptions{Filter: FilterBlobless, Update: UpdateResetToRemote}}
+	if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+		t.Fatalf("Failed to clone the remote: %v", err)
+	}
+	commitFile(t, remote, "remote.txt", "remote\n")
+	if err := os.WriteFile(filepath.Join(target.clonePath(), "local.txt"), []byte("local\n"), 0644); err != nil {
+		t.Fatalf("Failed to write local.txt: %v", err)
+	}
+	for _, args := range [][]string{{"add", "local.txt"}, {"commit", "--quiet", "-m", "local"}} {
+		if _, err := runGit(context.Background(), target.clonePath(), gitCommitEnv, nil, args...); err != nil {
+			t.Fatalf("Failed to commit in the clone: %v", err)
+		}
+	}
+	branch := strings.TrimSpace(gitOutput(t, target.clonePath(), "symbolic-ref", "--short", "HEAD"))
+	local := strings.TrimSpace(gitOutput(t, target.clonePath(), "rev-parse", "HEAD"))
+
+	if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+		t.Fatalf("cloneRepo() failed to reset a diverged partial clone: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target.clonePath(), "remote.txt")); err != nil {
+		t.Errorf("the reset did not bring the remote commit: %v", err)
+	}
+	backup := backupBranch(branch, plumbing.NewHash(local))
+	if kept := strings.TrimSpace(gitOutput(t, target.clonePath(), "rev-parse", backup)); kept != local {
+		t.Errorf("the backup branch %s points to %s, expected the local commit %s", backup, kept, local)
+	}
+}
+
+func TestUpdateRepo_ShallowPartialClone(t *testing.T) {
+	remote, url := newFilterRemote(t)
+	commitFile(t, remote, "second.txt", "second\n")
+	target := cloneTarget{Name: "api", URL: url, Dir: t.TempDir(), Options: &CloneOptions{Filter: FilterBlobless, Depth: 1, Update: UpdatePull}}
+	if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+		t.Fatalf("Failed to clone the remote: %v", err)
+	}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		commitFile(t, remote, name, name+"\n")
+	}
+
+	if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+		t.Fatalf("cloneRepo() failed to pull into a shallow partial clone: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target.clonePath(), "c.txt")); err != nil {
+		t.Errorf("the pull did not bring the new remote commits: %v", err)
+	}
+}
+
+// gitOutput runs the git binary in dir and returns its output
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := runGit(context.Background(), dir, nil, nil, args...)
+	if err != nil {
+		t.Fatalf("Failed to run git %s: %v", args[0], err)
+	}
+	return out
+}
+
+func TestCloneRepo_PartialCloneTransientFailure(t *testing.T) {
+	if !gitAvailable() {
+		t.Skip("the git binary is not in the PATH")
+	}
+	// nothing listens on the port, the connection is refused
+	target := cloneTarget{Name: "api", URL: "http://127.0.0.1:1/acme/api.git", Dir: t.TempDir(), Options: &CloneOptions{Filter: FilterBlobless}}
+
+	err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil)
+	if class, transient := retry.Classify(err); !transient || class != retry.Network {
+		t.Errorf("Classify(%v) = %q, %v, expected a transient network failure", err, class, transient)
+	}
+}
+
+func TestAuthenticator_GitEnv(t *testing.T) {
+	auth := newAuthenticator(nil)
+
+	env, err := auth.gitEnv(&AuthConfig{OAuthToken: "secret"}, "https://github.com/acme/api.git")
+	if err != nil {
+		t.Fatalf("gitEnv() returned an error: %v", err)
+	}
+	header := "GIT_CONFIG_VALUE_0=Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("x-access-token:secret"))
+	if len(env) != 3 || env[1] != "GIT_CONFIG_KEY_0=http.extraHeader" || env[2] != header {
+		t.Errorf("gitEnv() = %q", env)
+	}
+
+	env, _ = auth.gitEnv(&AuthConfig{SSHKey: "/keys/it's"}, "git@github.com:acme/api.git")
+	if len(env) != 1 || env[0] != `GIT_SSH_COMMAND=ssh -i '/keys/it'\''s' -o IdentitiesOnly=yes` {
+		t.Errorf("gitEnv() = %q", env)
+	}
+}
@@ -0,0 +1,126 @@
+package fetch
+
+import (
+	"fmt"
+	"os"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/resolve"
+)
+
+// tokenEnvVars are the environment variables holding the API token of each provider kind
+var tokenEnvVars = map[resolve.ProviderKind]string{
+	resolve.KindGitHub: "GITHUB_TOKEN",
+	resolve.KindGitLab: "GITLAB_TOKEN",
+	resolve.KindGitea:  "GITEA_TOKEN",
+}
+
+// host is a hosting service used by the config
+type host struct {
+	resolve.HostOptions
+	Kind resolve.ProviderKind
+}
+
+// cloneUsername is the username that goes along with the token for https clones
+func (h host) cloneUsername() string {
+	if h.Kind == resolve.KindGitLab {
+		return "oauth2"
+	}
+	return "x-access-token"
+}
+
+// configHosts returns every host the config uses, keyed by name.
+// Hosts come from the hosts config, or are well known ones. A token set in the config wins over the environment.
+func configHosts(config Config) (map[string]host, error) {
+	configured := make(map[string]host)
+	for _, h := range config.Hosts {
+		kind, err := hostKind(h)
+		if err != nil {
+			return nil, fmt.Errorf("host '%s': %w", h.Host, err)
+		}
+		configured[h.Host] = host{
+			HostOptions: resolve.HostOptions{
+				Host:      h.Host,
+				APIURL:    h.APIURL,
+				UploadURL: h.UploadURL,
+				SSHHost:   h.SSHHost,
+				Token:     h.Token,
+			},
+			Kind: kind,
+		}
+	}
+
+	hosts := make(map[string]host)
+	use := func(name string, required bool) error {
+		if _, ok := hosts[name]; ok {
+			return nil
+		}
+		h, ok := configured[name]
+		if !ok {
+			var kind resolve.ProviderKind
+			if kind, ok = resolve.KnownKind(name); ok {
+				h = host{HostOptions: resolve.HostOptions{Host: name}, Kind: kind}
+			}
+		}
+		if !ok {
+			if required {
+				return fmt.Errorf("unknown host '%s', add it to the hosts config along with its type", name)
+			}
+			return nil
+		}
+		if h.Token == "" {
+			h.Token = os.Getenv(tokenEnvVars[h.Kind])
+		}
+		hosts[name] = h
+		return nil
+	}
+
+	for name := range configured {
+		_ = use(name, false)
+	}
+	if err := use(DefaultHost, true); err != nil {
+		return nil, err
+	}
+	for _, path := range config.Paths {
+		for _, org := range path.Orgs {
+			if err := use(org.HostName(), true); err != nil {
+				return nil, fmt.Errorf("org '%s': %w", org.Name, err)
+			}
+		}
+		for _, query := range path.Queries {
+			if err := use(query.HostName(), true); err != nil {
+				return nil, fmt.Errorf("query '%s': %w", query.Query, err)
+			}
+		}
+		for _, repo := range path.Repos {
+			if ref, err := resolve.ParseRepoURL(repo.Url); err == nil {
+				_ = use(ref.Host, false)
+			}
+		}
+	}
+
+	return hosts, nil
+}
+
+// hostKind returns the provider kind of a configured host, which is optional for the well known hosts
+func hostKind(h HostConfig) (resolve.ProviderKind, error) {
+	if h.Type == "" {
+		if kind, ok := resolve.KnownKind(h.Host); ok {
+			return kind, nil
+		}
+		return "", fmt.Errorf("the type is required for hosts other than the well known ones")
+	}
+	return resolve.ParseProviderKind(h.Type)
+}
+
+// newResolver registers a provider for every host, all of them calling their API through the transport
+func newResolver(hosts map[string]host, transport *apiTransport) (*resolve.Resolver, error) {
+	resolver := resolve.NewResolver(hosts[DefaultHost].Token, transport)
+	for _, h := range hosts {
+		h.Transport, h.Cached = transport, transport.cache != nil
+		if err := resolver.RegisterHost(h.Kind, h.HostOptions); err != nil {
+			return nil, err
+		}
+	}
+	return resolver, nil
+}
@@ -0,0 +1,68 @@
+package fetch
+
+import (
+	"testing"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/resolve"
+)
+
+func TestConfigHosts(t *testing.T) {
+	t.Setenv("GITLAB_TOKEN", "gitlab-secret")
+
+	config := Config{
+		Hosts: []HostConfig{
+			{Host: "git.corp.example", Type: "forgejo", Token: "corp-secret"},
+			{Host: "gitlab.com", SSHHost: "altssh.gitlab.com"},
+		},
+		Paths: []Path{{
+			Orgs: []GithubOrgConfig{
+				{Name: "acme"},
+				{Name: "acme/platform", Host: "gitlab.com"},
+				{Name: "infra", Host: "git.corp.example"},
+			},
+		}},
+	}
+
+	hosts, err := configHosts(config)
+	if err != nil {
+		t.Fatalf("configHosts() returned an error: %v", err)
+	}
+
+	expected := map[string]resolve.ProviderKind{
+		"github.com":       resolve.KindGitHub,
+		"gitlab.com":       resolve.KindGitLab,
+		"git.corp.example": resolve.KindGitea,
+	}
+	for name, kind := range expected {
+		if hosts[name].Kind != kind {
+			t.Errorf("host %s has kind %q, expected %q", name, hosts[name].Kind, kind)
+		}
+	}
+	if hosts["gitlab.com"].Token != "gitlab-secret" || hosts["gitlab.com"].cloneUsername() != "oauth2" {
+		t.Errorf("gitlab.com did not get its token from GITLAB_TOKEN: %+v", hosts["gitlab.com"])
+	}
+	if hosts["gitlab.com"].SSHHost != "altssh.gitlab.com" {
+		t.Errorf("gitlab.com lost its configured ssh host: %+v", hosts["gitlab.com"])
+	}
+	if hosts["git.corp.example"].Token != "corp-secret" {
+		t.Errorf("the configured token of git.corp.example was not used: %+v", hosts["git.corp.example"])
+	}
+
+	config.Hosts = append(config.Hosts, HostConfig{Host: "git.other.example"})
+	if _, err := configHosts(config); err == nil {
+		t.Errorf("configHosts() accepted an unknown host without a type")
+	}
+	config.Hosts = config.Hosts[:len(config.Hosts)-1]
+
+	config.Paths[0].Queries = []QueryConfig{{Query: "org:acme", Host: "github.corp.example"}}
+	if _, err := configHosts(config); err == nil {
+		t.Errorf("configHosts() accepted a query on an unknown host")
+	}
+	config.Paths[0].Queries = nil
+
+	config.Paths[0].Orgs = append(config.Paths[0].Orgs, GithubOrgConfig{Name: "x", Host: "unknown.example"})
+	if _, err := configHosts(config); err == nil {
+		t.Errorf("configHosts() accepted an org on an unknown host")
+	}
+}
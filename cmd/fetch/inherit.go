@@ -0,0 +1,156 @@
+package fetch
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+)
+
+// configLevel names a level of the config hierarchy
+type configLevel string
+
+const (
+	levelDefault configLevel = "default"
+	levelGlobal  configLevel = "global"
+	levelPath    configLevel = "path"
+	levelOrg     configLevel = "org"
+	levelQuery   configLevel = "query"
+	levelRepo    configLevel = "repo"
+)
+
+// configLayer holds the clone options and the auth set on one level of the config
+type configLayer struct {
+	Level configLevel
+	Clone *CloneOptions
+	Auth  *AuthConfig
+}
+
+// effectiveOptions are the options that apply to a repository once all the config levels are overlaid
+type effectiveOptions struct {
+	Clone   CloneOptions
+	Auth    AuthConfig
+	Sources map[string]configLevel // the level each option came from, keyed by its config key
+}
+
+// defaultLayer holds the values used when no level of the config sets them
+var defaultLayer = configLayer{
+	Level: levelDefault,
+	Clone: &CloneOptions{Update: UpdateSkip, Mode: ModeWorktree, Tags: TagsAll, Cache: CacheNone, RecurseDepth: defaultRecurseDepth},
+}
+
+// layersFor returns the config layers that apply to a path's repos or orgs, from the least to the most specific.
+// Pass nil for the levels that don't apply.
+func layersFor(config Config, path Path, org *GithubOrgConfig, repo *RepoConfig) []configLayer {
+	layers := []configLayer{
+		defaultLayer,
+		{Level: levelGlobal, Clone: config.Clone, Auth: config.Auth},
+		{Level: levelPath, Clone: path.CloneOptions, Auth: path.Auth},
+	}
+	if org != nil {
+		layers = append(layers, configLayer{Level: levelOrg, Clone: org.OrgCloneOptions, Auth: org.Auth})
+	}
+	if repo != nil {
+		layers = append(layers, configLayer{Level: levelRepo, Clone: repo.RepoCloneOptions, Auth: repo.Auth})
+	}
+	return layers
+}
+
+// queryLayers returns the config layers that apply to the repositories found by a search query
+func queryLayers(config Config, path Path, query *QueryConfig) []configLayer {
+	return append(layersFor(config, path, nil, nil), configLayer{Level: levelQuery, Clone: query.QueryCloneOptions, Auth: query.Auth})
+}
+
+// resolveOptions overlays the layers field by field: a field set on a more specific level wins, even when it's set
+// to false, 0 or "". On every level the 'auth' of the clone options is overlaid first and the level's own 'auth' after it.
+func resolveOptions(layers ...configLayer) effectiveOptions {
+	eff := effectiveOptions{Sources: make(map[string]configLevel)}
+	clone := reflect.ValueOf(&eff.Clone).Elem()
+	auth := reflect.ValueOf(&eff.Auth).Elem()
+
+	for _, layer := range layers {
+		if layer.Clone != nil {
+			overlay(clone, reflect.ValueOf(layer.Clone).Elem(), layer.Clone.Keys, "", layer.Level, eff.Sources)
+			if layer.Clone.Auth != nil {
+				overlay(auth, reflect.ValueOf(layer.Clone.Auth).Elem(), layer.Clone.Auth.Keys, "auth.", layer.Level, eff.Sources)
+			}
+		}
+		if layer.Auth != nil {
+			overlay(auth, reflect.ValueOf(layer.Auth).Elem(), layer.Auth.Keys, "auth.", layer.Level, eff.Sources)
+		}
+	}
+	eff.Clone.Auth = nil
+	eff.Clone.Keys = nil
+	eff.Auth.Keys = nil
+
+	return eff
+}
+
+// overlay copies the fields of src set on a level over dst and records the level they came from, under their config
+// key prefixed by prefix. The fields set are the ones in keys, or the non-zero ones when keys is nil.
+// Pointer and map fields are skipped.
+func overlay(dst, src reflect.Value, keys map[string]bool, prefix string, level configLevel, sources map[string]configLevel) {
+	for i := 0; i < src.NumField(); i++ {
+		field := src.Field(i)
+		if field.Kind() == reflect.Pointer || field.Kind() == reflect.Map {
+			continue
+		}
+		key := configKey(src.Type().Field(i))
+		set := keys[key] || (keys == nil && !field.IsZero())
+		if !set {
+			continue
+		}
+		dst.Field(i).Set(field)
+		sources[prefix+key] = level
+	}
+}
+
+// explainRow is a single effective option, as printed by the explain command
+type explainRow struct {
+	Key   string
+	Value string
+	Level configLevel
+}
+
+// explain lists every effective option along with the level it came from
+func (e effectiveOptions) explain() []explainRow {
+	var rows []explainRow
+	collect := func(v reflect.Value, prefix string) {
+		for i := 0; i < v.NumField(); i++ {
+			if kind := v.Field(i).Kind(); kind == reflect.Pointer || kind == reflect.Map {
+				continue
+			}
+			key := prefix + configKey(v.Type().Field(i))
+			level, ok := e.Sources[key]
+			if !ok {
+				level = levelDefault
+			}
+			rows = append(rows, explainRow{Key: key, Value: displayValue(key, v.Field(i)), Level: level})
+		}
+	}
+	collect(reflect.ValueOf(e.Clone), "")
+	collect(reflect.ValueOf(e.Auth), "auth.")
+
+	return rows
+}
+
+// configKey returns the config file key of a struct field
+func configKey(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+	if name == "" {
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// displayValue formats an option for printing, masking the secrets
+func displayValue(key string, v reflect.Value) string {
+	if v.IsZero() {
+		return "-"
+	}
+	if key == "auth.password" || key == "auth.oauth_token" {
+		return "****"
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,111 @@
+package fetch
+
+import (
+	"testing"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+)
+
+func TestResolveOptions_FieldByField(t *testing.T) {
+	config := Config{
+		Clone: &CloneOptions{Branch: "main", Depth: 1, Auth: &AuthConfig{Username: "global-user"}},
+		Auth:  &AuthConfig{SSHKey: "/global/key"},
+	}
+	path := Path{Path: "/work", CloneOptions: &CloneOptions{Update: UpdatePull}}
+	org := &GithubOrgConfig{Name: "acme", OrgCloneOptions: &CloneOptions{Depth: 10}, Auth: &AuthConfig{SSHKey: "/org/key"}}
+
+	eff := resolveOptions(layersFor(config, path, org, nil)...)
+
+	if eff.Clone.Branch != "main" || eff.Sources["branch"] != levelGlobal {
+		t.Errorf("branch = %q from %q, expected main from the global level", eff.Clone.Branch, eff.Sources["branch"])
+	}
+	if eff.Clone.Depth != 10 || eff.Sources["depth"] != levelOrg {
+		t.Errorf("depth = %d from %q, expected 10 from the org level", eff.Clone.Depth, eff.Sources["depth"])
+	}
+	if eff.Clone.Update != UpdatePull || eff.Sources["update"] != levelPath {
+		t.Errorf("update = %q from %q, expected pull from the path level", eff.Clone.Update, eff.Sources["update"])
+	}
+	if eff.Auth.SSHKey != "/org/key" || eff.Sources["auth.ssh_key"] != levelOrg {
+		t.Errorf("auth.ssh_key = %q from %q, expected /org/key from the org level", eff.Auth.SSHKey, eff.Sources["auth.ssh_key"])
+	}
+	if eff.Auth.Username != "global-user" || eff.Sources["auth.username"] != levelGlobal {
+		t.Errorf("auth.username = %q from %q, expected global-user from the global level", eff.Auth.Username, eff.Sources["auth.username"])
+	}
+	if eff.Clone.Auth != nil {
+		t.Errorf("the effective clone options should not carry auth, it's resolved separately")
+	}
+}
+
+func TestResolveOptions_ZeroOverrides(t *testing.T) {
+	config := Config{Clone: &CloneOptions{Recurse: true, SingleBranch: true, Depth: 1, Branch: "main"}}
+	repo := &RepoConfig{
+		Url:              "git@github.com:acme/api.git",
+		RepoCloneOptions: &CloneOptions{Keys: map[string]bool{"recurse": true, "single_branch": true, "depth": true}},
+	}
+
+	eff := resolveOptions(layersFor(config, Path{}, nil, repo)...)
+
+	if eff.Clone.Recurse || eff.Clone.SingleBranch || eff.Clone.Depth != 0 {
+		t.Errorf("the repo level did not turn off recurse, single_branch and depth: %+v", eff.Clone)
+	}
+	if eff.Sources["depth"] != levelRepo {
+		t.Errorf("depth comes from %q, expected the repo level", eff.Sources["depth"])
+	}
+	if eff.Clone.Branch != "main" || eff.Sources["branch"] != levelGlobal {
+		t.Errorf("branch = %q from %q, expected main from the global level", eff.Clone.Branch, eff.Sources["branch"])
+	}
+}
+
+func TestResolveOptions_AuthZeroOverrides(t *testing.T) {
+	config := Config{Auth: &AuthConfig{OAuthToken: "global-token"}}
+	tests := []struct {
+		name     string
+		auth     *AuthConfig
+		expected authKind
+	}{
+		{name: "inherited token", auth: &AuthConfig{Username: "bob", Password: "secret"}, expected: authOAuthToken},
+		{
+			name:     "token turned off",
+			auth:     &AuthConfig{Username: "bob", Password: "secret", Keys: map[string]bool{"username": true, "password": true, "oauth_token": true}},
+			expected: authBasic,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &RepoConfig{Url: "https://github.com/acme/api.git", Auth: tt.auth}
+
+			eff := resolveOptions(layersFor(config, Path{}, nil, repo)...)
+
+			if eff.Auth.Username != "bob" || eff.Auth.Password != "secret" || eff.Sources["auth.password"] != levelRepo {
+				t.Errorf("auth = %+v, expected the repo's credentials", eff.Auth)
+			}
+			if kind := newAuthenticator(nil).kind(&eff.Auth, repo.Url); kind != tt.expected {
+				t.Errorf("kind() = %s, expected %s", kind, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveOptions_Defaults(t *testing.T) {
+	eff := resolveOptions(layersFor(Config{}, Path{}, nil, &RepoConfig{Url: "git@github.com:acme/repo.git"})...)
+
+	if eff.Clone.Update != UpdateSkip || eff.Sources["update"] != levelDefault {
+		t.Errorf("update = %q from %q, expected skip from the defaults", eff.Clone.Update, eff.Sources["update"])
+	}
+
+	for _, row := range eff.explain() {
+		if row.Key == "depth" && (row.Value != "-" || row.Level != levelDefault) {
+			t.Errorf("explain() shows depth as %q from %q, expected an unset default", row.Value, row.Level)
+		}
+	}
+}
+
+func TestExplain_MasksSecrets(t *testing.T) {
+	eff := resolveOptions(configLayer{Level: levelRepo, Auth: &AuthConfig{Password: "secret", OAuthToken: "token"}})
+
+	for _, row := range eff.explain() {
+		if row.Value == "secret" || row.Value == "token" {
+			t.Errorf("explain() leaked the secret %s", row.Key)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package model
+
+import "fmt"
+
+// CacheMode tells if and how a repository is cloned from the shared mirror cache instead of from its remote.
+type CacheMode string
+
+const (
+	// CacheNone clones straight from the remote
+	CacheNone CacheMode = "none"
+	// CacheAlternates clones from the cached mirror, borrowing its objects through .git/objects/info/alternates.
+	// The clone breaks if the cache is deleted.
+	CacheAlternates CacheMode = "alternates"
+	// CacheCopy clones from the cached mirror, copying its objects
+	CacheCopy CacheMode = "copy"
+)
+
+// OrDefault returns the cache mode, or CacheNone when none was configured.
+func (m CacheMode) OrDefault() CacheMode {
+	if m == "" {
+		return CacheNone
+	}
+	return m
+}
+
+// Enabled tells if the repository is cloned from the cache
+func (m CacheMode) Enabled() bool {
+	return m.OrDefault() != CacheNone
+}
+
+// Validate returns an error for unknown cache modes. An empty mode is valid and means CacheNone.
+func (m CacheMode) Validate() error {
+	switch m {
+	case "", CacheNone, CacheAlternates, CacheCopy:
+		return nil
+	}
+	return fmt.Errorf("unknown cache mode '%s', valid values are %s, %s and %s", m, CacheNone, CacheAlternates, CacheCopy)
+}
@@ -0,0 +1,22 @@
+package model
+
+import "fmt"
+
+// CloneFilter is a partial clone filter: the objects left out of a clone, fetched later when they are needed.
+type CloneFilter string
+
+const (
+	// FilterBlobless leaves out the file contents, like `git clone --filter=blob:none`
+	FilterBlobless CloneFilter = "blob:none"
+	// FilterTreeless leaves out the file contents and the directory trees, like `git clone --filter=tree:0`
+	FilterTreeless CloneFilter = "tree:0"
+)
+
+// Validate returns an error for unknown filters. An empty filter is valid and means a full clone.
+func (f CloneFilter) Validate() error {
+	switch f {
+	case "", FilterBlobless, FilterTreeless:
+		return nil
+	}
+	return fmt.Errorf("unknown filter '%s', valid values are %s and %s", f, FilterBlobless, FilterTreeless)
+}
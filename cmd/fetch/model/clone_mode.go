@@ -0,0 +1,37 @@
+package model
+
+import "fmt"
+
+// CloneMode tells what a repository is cloned as.
+type CloneMode string
+
+const (
+	// ModeWorktree clones a regular repository with its files checked out
+	ModeWorktree CloneMode = "worktree"
+	// ModeBare clones the repository without a worktree, its branches kept as local branches
+	ModeBare CloneMode = "bare"
+	// ModeMirror clones a bare repository holding every ref of the remote, like `git clone --mirror`
+	ModeMirror CloneMode = "mirror"
+)
+
+// OrDefault returns the mode, or ModeWorktree when none was configured.
+func (m CloneMode) OrDefault() CloneMode {
+	if m == "" {
+		return ModeWorktree
+	}
+	return m
+}
+
+// IsBare tells if the mode clones without a worktree
+func (m CloneMode) IsBare() bool {
+	return m == ModeBare || m == ModeMirror
+}
+
+// Validate returns an error for unknown modes. An empty mode is valid and means ModeWorktree.
+func (m CloneMode) Validate() error {
+	switch m {
+	case "", ModeWorktree, ModeBare, ModeMirror:
+		return nil
+	}
+	return fmt.Errorf("unknown clone mode '%s', valid values are %s, %s and %s", m, ModeWorktree, ModeBare, ModeMirror)
+}
@@ -1,10 +1,27 @@
 package model
 
+import "time"
+
+// DefaultHost is the host of the orgs that don't configure one
+const DefaultHost = "github.com"
+
 type CloneOptions struct {
-	Branch  string      `mapstructure:"branch,omitempty"`
-	Depth   int         `mapstructure:"depth,omitempty"`
-	Recurse bool        `mapstructure:"recurse,omitempty"` // For recursive cloning
-	Auth    *AuthConfig `mapstructure:"auth,omitempty"`
+	Branch       string         `mapstructure:"branch,omitempty"`
+	Depth        int            `mapstructure:"depth,omitempty"`
+	Recurse      bool           `mapstructure:"recurse,omitempty"`       // Initialize the submodules, and theirs, when cloning and when pulling or resetting
+	RecurseDepth int            `mapstructure:"recurse_depth,omitempty"` // Levels of nested submodules recurse goes down, 1 being only the direct ones. Defaults to 10
+	Update       UpdateStrategy `mapstructure:"update,omitempty"`        // What to do with repos that already exist on disk
+	Mode         CloneMode      `mapstructure:"mode,omitempty"`          // worktree (the default), bare or mirror
+	Filter       CloneFilter    `mapstructure:"filter,omitempty"`        // Partial clone filter: blob:none or tree:0. Needs the git binary
+	SingleBranch bool           `mapstructure:"single_branch,omitempty"` // Only fetch a single branch, the default one unless branch is set. Implied by branch
+	AllBranches  bool           `mapstructure:"all_branches,omitempty"`  // Fetch every branch and create a local branch for each. Wins over single_branch
+	Tags         TagMode        `mapstructure:"tags,omitempty"`          // none, all (the default) or following
+	Cache        CacheMode      `mapstructure:"cache,omitempty"`         // Clone from the shared mirror cache: none (the default), alternates or copy
+	Auth         *AuthConfig    `mapstructure:"auth,omitempty"`
+
+	// Keys are the config keys set on this level, so that the ones set to false, 0 or "" still override the inherited
+	// values. They're recorded when the config is read; when nil, the non-zero fields are the ones set.
+	Keys map[string]bool `mapstructure:"-"`
 }
 
 type RepoFilterConfig struct {
@@ -14,21 +31,44 @@ type RepoFilterConfig struct {
 	UpdatedBefore    string   `mapstructure:"updated_before,omitempty"`    // Only include repos updated before this date
 	IncludeLanguages []string `mapstructure:"include_languages,omitempty"` // Only include repos with these languages
 	ExcludeLanguages []string `mapstructure:"exclude_languages,omitempty"` // Exclude repos with these languages
+	Archived         *bool    `mapstructure:"archived,omitempty"`          // Only archived repos if true, no archived repos if false
+	Fork             *bool    `mapstructure:"fork,omitempty"`              // Only forks if true, no forks if false
+	Template         *bool    `mapstructure:"template,omitempty"`          // Only templates if true, no templates if false
+	Visibility       []string `mapstructure:"visibility,omitempty"`        // Only include repos with these visibilities: public, private, internal
+	Topics           []string `mapstructure:"topics,omitempty"`            // Only include repos having at least one of these topics
+	ExcludeTopics    []string `mapstructure:"exclude_topics,omitempty"`    // Exclude repos having any of these topics
+	MinSize          int      `mapstructure:"min_size,omitempty"`          // Minimum size in KB
+	MaxSize          int      `mapstructure:"max_size,omitempty"`          // Maximum size in KB
+	DefaultBranches  []string `mapstructure:"default_branches,omitempty"`  // Only include repos whose default branch is one of these
 }
 
 type RepoOrderConfig struct {
-	Field     string `mapstructure:"field"`     // Field to sort by. Valid values are "created", "updated", "pushed", "full_name", "size"
-	Direction string `mapstructure:"direction"` // Direction to sort. Either "asc" or "desc".
+	Field     string `mapstructure:"field"`     // Field to sort by. Valid values are "created", "updated", "pushed", "full_name", "size", "stars"
+	Direction string `mapstructure:"direction"` // Direction to sort. Either "asc" or "desc". Defaults to "asc" for "full_name" and "desc" otherwise.
 }
 
 type GithubOrgConfig struct {
 	Name            string            `mapstructure:"name"`
-	ExcludeRepos    []string          `mapstructure:"exclude_repos,omitempty"`
+	Host            string            `mapstructure:"host,omitempty"`              // Host the org lives on, defaults to github.com
+	IncludeRepos    []string          `mapstructure:"include_repos,omitempty"`     // Only include these repos, as globs or as regexes prefixed by "re:"
+	ExcludeRepos    []string          `mapstructure:"exclude_repos,omitempty"`     // Exclude these repos, as globs or as regexes prefixed by "re:"
 	OrgCloneOptions *CloneOptions     `mapstructure:"org_clone_options,omitempty"` // Custom Clone options per Org level
 	Auth            *AuthConfig       `mapstructure:"auth,omitempty"`
-	RepoFilter      *RepoFilterConfig `mapstructure:"repo_filter,omitempty"` // Filter out repositories based on certain conditions
-	RepoOrder       *RepoOrderConfig  `mapstructure:"repo_order,omitempty"`  // Order repositories based on a field
-	RepoLimit       int               `mapstructure:"repo_limit,omitempty"`  // Limit the number of repositories to be cloned
+	RepoFilter      *RepoFilterConfig `mapstructure:"repo_filter,omitempty"`  // Filter out repositories based on certain conditions
+	RepoOrder       *RepoOrderConfig  `mapstructure:"repo_order,omitempty"`   // Order repositories based on a field
+	RepoLimit       int               `mapstructure:"repo_limit,omitempty"`   // Limit the number of repositories to be cloned
+	Affiliation     []string          `mapstructure:"affiliation,omitempty"`  // Only for "@me": owner, collaborator and/or organization_member
+	Teams           []string          `mapstructure:"teams,omitempty"`        // Only the repos these teams, given by slug, can access
+	Topics          []string          `mapstructure:"topics,omitempty"`       // Only the repos tagged with these topics
+	TopicsMatch     TopicsMatch       `mapstructure:"topics_match,omitempty"` // any (the default) or all of the topics
+}
+
+// HostName returns the host the org lives on
+func (o GithubOrgConfig) HostName() string {
+	if o.Host == "" {
+		return DefaultHost
+	}
+	return o.Host
 }
 
 type RepoConfig struct {
@@ -37,25 +77,67 @@ type RepoConfig struct {
 	Auth             *AuthConfig   `mapstructure:"auth,omitempty"`
 }
 
+// QueryConfig selects the repositories returned by a GitHub repository search
+type QueryConfig struct {
+	Query             string        `mapstructure:"query"`                         // e.g. org:acme language:go pushed:>2024-01-01
+	Host              string        `mapstructure:"host,omitempty"`                // GitHub host to search on, defaults to github.com
+	QueryCloneOptions *CloneOptions `mapstructure:"query_clone_options,omitempty"` // Custom Clone options per query level
+	Auth              *AuthConfig   `mapstructure:"auth,omitempty"`
+}
+
+// HostName returns the host the query searches on
+func (q QueryConfig) HostName() string {
+	if q.Host == "" {
+		return DefaultHost
+	}
+	return q.Host
+}
+
 type Path struct {
 	Path         string            `mapstructure:"path"`
 	Repos        []RepoConfig      `mapstructure:"repos,omitempty"`
 	Orgs         []GithubOrgConfig `mapstructure:"orgs,omitempty"`
+	Queries      []QueryConfig     `mapstructure:"queries,omitempty"`
 	CloneOptions *CloneOptions     `mapstructure:"path_clone_options,omitempty"` // Custom Clone options per Path level
 	Auth         *AuthConfig       `mapstructure:"auth,omitempty"`
 }
 
+// HostConfig configures a git hosting service the orgs can live on
+type HostConfig struct {
+	Host      string `mapstructure:"host"`
+	Type      string `mapstructure:"type,omitempty"`       // API flavor: github, gitlab, gitea or forgejo. Optional for well known hosts
+	APIURL    string `mapstructure:"api_url,omitempty"`    // e.g. https://git.corp.example/api/v3/ for GitHub Enterprise Server
+	UploadURL string `mapstructure:"upload_url,omitempty"` // GitHub Enterprise Server upload url, defaults to api_url
+	SSHHost   string `mapstructure:"ssh_host,omitempty"`   // Host of the ssh clone urls, when it differs from host
+	Token     string `mapstructure:"token,omitempty"`      // API token, defaults to the GITHUB_TOKEN, GITLAB_TOKEN or GITEA_TOKEN env var
+}
+
 type AuthConfig struct {
 	Username   string `mapstructure:"username,omitempty"`    // for Basic Auth
 	Password   string `mapstructure:"password,omitempty"`    // for Basic Auth
 	SSHKey     string `mapstructure:"ssh_key,omitempty"`     // for SSH
 	OAuthToken string `mapstructure:"oauth_token,omitempty"` // for OAuth
+
+	// Keys are the config keys set on this level, like the Keys of CloneOptions
+	Keys map[string]bool `mapstructure:"-"`
+}
+
+// RetryConfig tells how the transient failures of the API calls, clones and fetches are tried again
+type RetryConfig struct {
+	Attempts  int           `mapstructure:"attempts,omitempty"`   // Tries in total, 1 disables retries. Defaults to 3
+	BaseDelay time.Duration `mapstructure:"base_delay,omitempty"` // Wait before the first retry, doubled for every next one. Defaults to 1s
+	MaxDelay  time.Duration `mapstructure:"max_delay,omitempty"`  // Longest wait between tries. Defaults to 30s
+	Jitter    *float64      `mapstructure:"jitter,omitempty"`     // Random share of each wait taken off, from 0 to 1. Defaults to 0.2
+	RetryOn   []string      `mapstructure:"retry_on,omitempty"`   // Failures retried: network, timeout, server, rate_limit. Defaults to network, timeout and server
 }
 
 type Config struct {
-	// tmp: org name (to be removed)
-	OrgName string
-	Paths   []Path        `mapstructure:"paths"`
-	Clone   *CloneOptions `mapstructure:"global_clone_options,omitempty"`
-	Auth    *AuthConfig   `mapstructure:"auth,omitempty"`
+	Paths []Path        `mapstructure:"paths"`
+	Clone *CloneOptions `mapstructure:"global_clone_options,omitempty"`
+	Auth  *AuthConfig   `mapstructure:"auth,omitempty"`
+
+	Concurrency int          `mapstructure:"concurrency,omitempty"` // Number of repositories cloned in parallel
+	Hosts       []HostConfig `mapstructure:"hosts,omitempty"`       // Hosting services besides the well known ones
+	Retry       *RetryConfig `mapstructure:"retry,omitempty"`       // Retry policy for transient failures
+	CacheDir    string       `mapstructure:"cache_dir,omitempty"`   // Mirrors the cached clones are made from, defaults to git-intel/mirrors in the user's cache directory
 }
@@ -1,5 +1,7 @@
 package model
 
+import "fmt"
+
 // RepositoryListSort represents the field to sort the repository list by.
 type RepositoryListSort int
 type RepositoryListSortDirection bool
@@ -9,6 +11,8 @@ const (
 	RepoListSortUpdated
 	RepoListSortPushed
 	RepoListSortFullName
+	RepoListSortSize
+	RepoListSortStars
 )
 
 // String returns the string representation of a RepositoryListSort.
@@ -21,5 +25,23 @@ func (r RepositoryListSort) String() string {
 		RepoListSortUpdated:  "updated",
 		RepoListSortPushed:   "pushed",
 		RepoListSortFullName: "full_name",
+		RepoListSortSize:     "size",
+		RepoListSortStars:    "stars",
 	}[r]
 }
+
+// ServerSide tells if the GitHub API can sort the repository list by this field.
+// The other fields can only be sorted client-side.
+func (r RepositoryListSort) ServerSide() bool {
+	return r <= RepoListSortFullName
+}
+
+// ParseRepositoryListSort returns the RepositoryListSort with the given string representation.
+func ParseRepositoryListSort(s string) (RepositoryListSort, error) {
+	for r := RepoListSortCreated; r <= RepoListSortStars; r++ {
+		if r.String() == s {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown sort field '%s', valid values are created, updated, pushed, full_name, size and stars", s)
+}
@@ -0,0 +1,32 @@
+package model
+
+import "fmt"
+
+// TagMode tells which tags are fetched along with the branches.
+type TagMode string
+
+const (
+	// TagsNone fetches no tags
+	TagsNone TagMode = "none"
+	// TagsAll fetches every tag of the remote
+	TagsAll TagMode = "all"
+	// TagsFollowing fetches the tags pointing into the fetched history
+	TagsFollowing TagMode = "following"
+)
+
+// OrDefault returns the mode, or TagsAll when none was configured.
+func (m TagMode) OrDefault() TagMode {
+	if m == "" {
+		return TagsAll
+	}
+	return m
+}
+
+// Validate returns an error for unknown modes. An empty mode is valid and means TagsAll.
+func (m TagMode) Validate() error {
+	switch m {
+	case "", TagsNone, TagsAll, TagsFollowing:
+		return nil
+	}
+	return fmt.Errorf("unknown tags '%s', valid values are %s, %s and %s", m, TagsNone, TagsAll, TagsFollowing)
+}
@@ -0,0 +1,30 @@
+package model
+
+import "fmt"
+
+// TopicsMatch tells how many of an org's topics a repository has to be tagged with to be selected.
+type TopicsMatch string
+
+const (
+	// TopicsMatchAny selects the repositories tagged with at least one of the topics
+	TopicsMatchAny TopicsMatch = "any"
+	// TopicsMatchAll selects the repositories tagged with every topic
+	TopicsMatchAll TopicsMatch = "all"
+)
+
+// OrDefault returns the match, or TopicsMatchAny when none was configured.
+func (m TopicsMatch) OrDefault() TopicsMatch {
+	if m == "" {
+		return TopicsMatchAny
+	}
+	return m
+}
+
+// Validate returns an error for unknown matches. An empty match is valid and means TopicsMatchAny.
+func (m TopicsMatch) Validate() error {
+	switch m {
+	case "", TopicsMatchAny, TopicsMatchAll:
+		return nil
+	}
+	return fmt.Errorf("unknown topics_match '%s', valid values are %s and %s", m, TopicsMatchAny, TopicsMatchAll)
+}
@@ -0,0 +1,36 @@
+package model
+
+import "fmt"
+
+// UpdateStrategy tells what happens to a repository that already exists on disk.
+type UpdateStrategy string
+
+const (
+	// UpdateSkip leaves existing repositories untouched
+	UpdateSkip UpdateStrategy = "skip"
+	// UpdateFetch only fetches the remote refs, the worktree is left as it is
+	UpdateFetch UpdateStrategy = "fetch"
+	// UpdatePull fetches and fast-forwards the checked out branch, like `git pull --ff-only`
+	UpdatePull UpdateStrategy = "pull"
+	// UpdateResetToRemote fetches, checks out the configured branch and hard resets it to its remote head.
+	// The commits of a diverged branch are kept in a backup branch.
+	UpdateResetToRemote UpdateStrategy = "reset-to-remote"
+)
+
+// OrDefault returns the strategy, or UpdateSkip when none was configured.
+func (u UpdateStrategy) OrDefault() UpdateStrategy {
+	if u == "" {
+		return UpdateSkip
+	}
+	return u
+}
+
+// Validate returns an error for unknown strategies. An empty strategy is valid and means UpdateSkip.
+func (u UpdateStrategy) Validate() error {
+	switch u {
+	case "", UpdateSkip, UpdateFetch, UpdatePull, UpdateResetToRemote:
+		return nil
+	}
+	return fmt.Errorf("unknown update strategy '%s', valid values are %s, %s, %s and %s",
+		u, UpdateSkip, UpdateFetch, UpdatePull, UpdateResetToRemote)
+}
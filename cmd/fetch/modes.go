@@ -0,0 +1,99 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// remoteBranchPrefix is where the branches of origin are fetched to
+const remoteBranchPrefix = "refs/remotes/" + git.DefaultRemoteName + "/"
+
+// singleBranch tells if only a single branch is fetched: when asked for or when a branch is set,
+// unless every branch is. Mirrors always have every ref.
+func singleBranch(o *CloneOptions) bool {
+	if o.Mode == ModeMirror || o.AllBranches {
+		return false
+	}
+	return o.SingleBranch || o.Branch != ""
+}
+
+// goGitTags maps the tags option to go-git's tag mode
+func goGitTags(tags TagMode) git.TagMode {
+	switch tags.OrDefault() {
+	case TagsNone:
+		return git.NoTags
+	case TagsFollowing:
+		return git.TagFollowing
+	}
+	return git.AllTags
+}
+
+// goGitCloneOptions returns the go-git options cloning a target
+func goGitCloneOptions(target cloneTarget, authMethod transport.AuthMethod) *git.CloneOptions {
+	o := target.Options
+	cloneOpts := &git.CloneOptions{
+		URL:          target.URL,
+		Depth:        o.Depth,
+		Auth:         authMethod,
+		Mirror:       o.Mode == ModeMirror,
+		SingleBranch: singleBranch(o),
+		Tags:         goGitTags(o.Tags),
+	}
+	if o.Branch != "" && o.Mode != ModeMirror {
+		cloneOpts.ReferenceName = plumbing.NewBranchReferenceName(o.Branch)
+	}
+	return cloneOpts
+}
+
+// packDir returns the directory the packs of a repository cloned into dir are written to
+func packDir(dir string, mode CloneMode) string {
+	if mode.IsBare() {
+		return filepath.Join(dir, "objects", "pack")
+	}
+	return filepath.Join(dir, git.GitDirName, "objects", "pack")
+}
+
+// syncLocalBranches creates a local branch, tracking origin, for every branch fetched from origin.
+// The existing local branches are moved to their origin counterpart only when overwrite is set,
+// which is meant for bare repositories where there's no worktree and no local work to lose.
+func syncLocalBranches(repo *git.Repository, overwrite bool) error {
+	iter, err := repo.References()
+	if err != nil {
+		return err
+	}
+	var remoteRefs []*plumbing.Reference
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && strings.HasPrefix(ref.Name().String(), remoteBranchPrefix) {
+			remoteRefs = append(remoteRefs, ref)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range remoteRefs {
+		branch := strings.TrimPrefix(ref.Name().String(), remoteBranchPrefix)
+		localName := plumbing.NewBranchReferenceName(branch)
+		if _, err := repo.Reference(localName, false); err == nil && !overwrite {
+			continue
+		}
+
+		if err := repo.Storer.SetReference(plumbing.NewHashReference(localName, ref.Hash())); err != nil {
+			return fmt.Errorf("can't create the local branch %s: %w", branch, err)
+		}
+		err := repo.CreateBranch(&config.Branch{Name: branch, Remote: git.DefaultRemoteName, Merge: localName})
+		if err != nil && !errors.Is(err, git.ErrBranchExists) {
+			return fmt.Errorf("can't configure the local branch %s: %w", branch, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package fetch
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// newBranchedRemote creates a remote with a master branch tagged v1 and a feature branch one commit ahead of it
+func newBranchedRemote(t *testing.T) string {
+	t.Helper()
+
+	dir := newLocalRemote(t)
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatalf("Failed to open the remote: %v", err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatalf("Failed to read the remote HEAD: %v", err)
+	}
+	if _, err := repo.CreateTag("v1", head.Hash(), nil); err != nil {
+		t.Fatalf("Failed to tag the remote: %v", err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get the remote worktree: %v", err)
+	}
+	feature := plumbing.NewBranchReferenceName("feature")
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: feature, Create: true}); err != nil {
+		t.Fatalf("Failed to create the feature branch: %v", err)
+	}
+	commitFile(t, dir, "feature.txt", "feature\n")
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: head.Name()}); err != nil {
+		t.Fatalf("Failed to check out %s again: %v", head.Name(), err)
+	}
+
+	return dir
+}
+
+// hasRef tells if the repository at dir has the given reference
+func hasRef(t *testing.T, dir string, name plumbing.ReferenceName) bool {
+	t.Helper()
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", dir, err)
+	}
+	_, err = repo.Reference(name, false)
+	return err == nil
+}
+
+func TestCloneRepo_Modes(t *testing.T) {
+	localFeature := plumbing.NewBranchReferenceName("feature")
+	remoteFeature := plumbing.NewRemoteReferenceName(git.DefaultRemoteName, "feature")
+	tag := plumbing.NewTagReferenceName("v1")
+
+	tests := []struct {
+		name     string
+		options  CloneOptions
+		worktree bool
+		present  []plumbing.ReferenceName
+		missing  []plumbing.ReferenceName
+	}{
+		{
+			name:     "worktree",
+			options:  CloneOptions{Mode: ModeWorktree},
+			worktree: true,
+			present:  []plumbing.ReferenceName{remoteFeature, tag},
+			missing:  []plumbing.ReferenceName{localFeature},
+		},
+		{
+			name:    "bare",
+			options: CloneOptions{Mode: ModeBare},
+			present: []plumbing.ReferenceName{localFeature, tag},
+		},
+		{
+			name:    "mirror",
+			options: CloneOptions{Mode: ModeMirror, Branch: "master", SingleBranch: true},
+			present: []plumbing.ReferenceName{localFeature, tag},
+			missing: []plumbing.ReferenceName{remoteFeature},
+		},
+		{
+			name:     "single branch",
+			options:  CloneOptions{SingleBranch: true},
+			worktree: true,
+			missing:  []plumbing.ReferenceName{remoteFeature, localFeature},
+		},
+		{
+			name:     "all branches",
+			options:  CloneOptions{Branch: "master", AllBranches: true},
+			worktree: true,
+			present:  []plumbing.ReferenceName{remoteFeature, localFeature},
+		},
+		{
+			name:     "no tags",
+			options:  CloneOptions{Tags: TagsNone},
+			worktree: true,
+			missing:  []plumbing.ReferenceName{tag},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote := newBranchedRemote(t)
+			target := cloneTarget{Name: "api", URL: remote, Dir: t.TempDir(), Options: &tt.options}
+
+			if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+				t.Fatalf("cloneRepo() returned an error: %v", err)
+			}
+
+			_, err := os.Stat(filepath.Join(target.clonePath(), "README.md"))
+			if tt.worktree != (err == nil) {
+				t.Errorf("the clone has a worktree: %v, expected %v", err == nil, tt.worktree)
+			}
+			for _, ref := range tt.present {
+				if !hasRef(t, target.clonePath(), ref) {
+					t.Errorf("the clone is missing %s", ref)
+				}
+			}
+			for _, ref := range tt.missing {
+				if hasRef(t, target.clonePath(), ref) {
+					t.Errorf("the clone has %s", ref)
+				}
+			}
+		})
+	}
+}
+
+func TestCloneRepo_MirrorConfig(t *testing.T) {
+	remote := newBranchedRemote(t)
+	target := cloneTarget{Name: "api", URL: remote, Dir: t.TempDir(), Options: &CloneOptions{Mode: ModeMirror}}
+	if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+		t.Fatalf("cloneRepo() returned an error: %v", err)
+	}
+
+	repo, err := git.PlainOpen(target.clonePath())
+	if err != nil {
+		t.Fatalf("Failed to open the mirror: %v", err)
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		t.Fatalf("Failed to read the mirror config: %v", err)
+	}
+	if !cfg.Core.IsBare || !cfg.Remotes[git.DefaultRemoteName].Mirror {
+		t.Errorf("the mirror is configured as bare: %v, mirror: %v", cfg.Core.IsBare, cfg.Remotes[git.DefaultRemoteName].Mirror)
+	}
+}
+
+func TestUpdateRepo_BareFollowsTheRemote(t *testing.T) {
+	for _, mode := range []CloneMode{ModeBare, ModeMirror} {
+		t.Run(string(mode), func(t *testing.T) {
+			remote := newBranchedRemote(t)
+			target := cloneTarget{Name: "api", URL: remote, Dir: t.TempDir(), Options: &CloneOptions{Mode: mode, Update: UpdatePull}}
+			if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+				t.Fatalf("Failed to clone the remote: %v", err)
+			}
+			commitFile(t, remote, "new.txt", "new\n")
+
+			if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+				t.Fatalf("cloneRepo() failed to update the %s clone: %v", mode, err)
+			}
+
+			remoteRepo, err := git.PlainOpen(remote)
+			if err != nil {
+				t.Fatalf("Failed to open the remote: %v", err)
+			}
+			remoteHead, err := remoteRepo.Head()
+			if err != nil {
+				t.Fatalf("Failed to read the remote HEAD: %v", err)
+			}
+			clone, err := git.PlainOpen(target.clonePath())
+			if err != nil {
+				t.Fatalf("Failed to open the clone: %v", err)
+			}
+			local, err := clone.Reference(remoteHead.Name(), false)
+			if err != nil || local.Hash() != remoteHead.Hash() {
+				t.Errorf("%s is at %v after the update, expected %s", remoteHead.Name(), local, remoteHead.Hash())
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package fetch
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/resolve"
+)
+
+// repoOrder is a validated RepoOrderConfig
+type repoOrder struct {
+	field RepositoryListSort
+	desc  bool
+	set   bool // false when no order was configured, the listing keeps the API's default order
+}
+
+// newRepoOrder validates the order config. A nil config keeps the order the repositories are listed in.
+func newRepoOrder(config *RepoOrderConfig) (*repoOrder, error) {
+	if config == nil {
+		return &repoOrder{field: RepoListSortUpdated, desc: true}, nil
+	}
+
+	field, err := ParseRepositoryListSort(config.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &repoOrder{field: field, desc: field != RepoListSortFullName, set: true}
+	switch strings.ToLower(config.Direction) {
+	case "":
+	case "asc":
+		o.desc = false
+	case "desc":
+		o.desc = true
+	default:
+		return nil, fmt.Errorf("unknown sort direction '%s', valid values are asc and desc", config.Direction)
+	}
+
+	return o, nil
+}
+
+// direction returns the sort direction as the GitHub API expects it
+func (o *repoOrder) direction() string {
+	if o.desc {
+		return "desc"
+	}
+	return "asc"
+}
+
+// listOptions asks the provider's API for the order, when it can sort by the field
+func (o *repoOrder) listOptions() resolve.ListOptions {
+	if !o.set || !o.field.ServerSide() {
+		return resolve.ListOptions{}
+	}
+	return resolve.ListOptions{Sort: o.field.String(), Direction: o.direction()}
+}
+
+// sort orders the repositories client-side. The sort is stable and runs even for the fields the API
+// already sorted by, so the order holds no matter how the list was put together.
+func (o *repoOrder) sort(repos []*resolve.Repository) {
+	if !o.set {
+		return
+	}
+
+	slices.SortStableFunc(repos, func(a, b *resolve.Repository) int {
+		c := o.compare(a, b)
+		if o.desc {
+			return -c
+		}
+		return c
+	})
+}
+
+func (o *repoOrder) compare(a, b *resolve.Repository) int {
+	switch o.field {
+	case RepoListSortCreated:
+		return a.CreatedAt.Compare(b.CreatedAt)
+	case RepoListSortUpdated:
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	case RepoListSortPushed:
+		return a.PushedAt.Compare(b.PushedAt)
+	case RepoListSortFullName:
+		return strings.Compare(strings.ToLower(a.FullName), strings.ToLower(b.FullName))
+	case RepoListSortSize:
+		return cmp.Compare(a.Size, b.Size)
+	case RepoListSortStars:
+		return cmp.Compare(a.Stars, b.Stars)
+	}
+	return 0
+}
+
+// limitRepos keeps the first limit repositories. A limit under 1 means no limit.
+func limitRepos(repos []*resolve.Repository, limit int) []*resolve.Repository {
+	if limit < 1 || len(repos) <= limit {
+		return repos
+	}
+	return repos[:limit]
+}
@@ -0,0 +1,74 @@
+package fetch
+
+import (
+	"slices"
+	"testing"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/resolve"
+)
+
+func namesOf(repos []*resolve.Repository) []string {
+	names := make([]string, len(repos))
+	for i, r := range repos {
+		names[i] = r.Name
+	}
+	return names
+}
+
+func TestRepoOrder_ClientSide(t *testing.T) {
+	repos := []*resolve.Repository{
+		{Name: "small", FullName: "acme/small", Size: 10, Stars: 5},
+		{Name: "big", FullName: "acme/big", Size: 1000, Stars: 1},
+		{Name: "medium", FullName: "acme/medium", Size: 100, Stars: 9},
+	}
+
+	tests := []struct {
+		config   RepoOrderConfig
+		expected []string
+	}{
+		{RepoOrderConfig{Field: "size"}, []string{"big", "medium", "small"}},
+		{RepoOrderConfig{Field: "size", Direction: "asc"}, []string{"small", "medium", "big"}},
+		{RepoOrderConfig{Field: "stars"}, []string{"medium", "small", "big"}},
+		{RepoOrderConfig{Field: "full_name"}, []string{"big", "medium", "small"}},
+	}
+
+	for _, tt := range tests {
+		order, err := newRepoOrder(&tt.config)
+		if err != nil {
+			t.Fatalf("newRepoOrder(%+v) returned an error: %v", tt.config, err)
+		}
+		sorted := append([]*resolve.Repository(nil), repos...)
+		order.sort(sorted)
+		if got := namesOf(sorted); !slices.Equal(got, tt.expected) {
+			t.Errorf("sort by %+v = %v, expected %v", tt.config, got, tt.expected)
+		}
+	}
+
+	if got := namesOf(limitRepos(repos, 2)); !slices.Equal(got, []string{"small", "big"}) {
+		t.Errorf("limitRepos() = %v, expected the first 2 repos", got)
+	}
+}
+
+func TestRepoOrder_ServerSide(t *testing.T) {
+	order, err := newRepoOrder(&RepoOrderConfig{Field: "pushed", Direction: "asc"})
+	if err != nil {
+		t.Fatalf("newRepoOrder() returned an error: %v", err)
+	}
+	opts := order.listOptions()
+	if opts.Sort != "pushed" || opts.Direction != "asc" {
+		t.Errorf("listOptions() set sort=%q direction=%q, expected pushed asc", opts.Sort, opts.Direction)
+	}
+
+	order, _ = newRepoOrder(&RepoOrderConfig{Field: "stars"})
+	if opts = order.listOptions(); opts.Sort != "" {
+		t.Errorf("listOptions() asked the API to sort by %q, which it doesn't support", opts.Sort)
+	}
+
+	if _, err := newRepoOrder(&RepoOrderConfig{Field: "forks"}); err == nil {
+		t.Errorf("newRepoOrder() accepted an unknown field")
+	}
+	if _, err := newRepoOrder(&RepoOrderConfig{Field: "size", Direction: "up"}); err == nil {
+		t.Errorf("newRepoOrder() accepted an unknown direction")
+	}
+}
@@ -0,0 +1,116 @@
+package fetch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+)
+
+// output formats of the plans
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// plan actions
+const (
+	actionClone  = "clone"
+	actionUpdate = "update"
+	actionSkip   = "skip"
+)
+
+// planEntry is what fetch would do with a single target
+type planEntry struct {
+	Repo   string `json:"repo"`
+	URL    string `json:"url"`
+	Target string `json:"target"`
+	Action string `json:"action"`
+	Update string `json:"update,omitempty"`
+	Mode   string `json:"mode"`
+	Filter string `json:"filter,omitempty"`
+	Cache  string `json:"cache,omitempty"`
+	Branch string `json:"branch,omitempty"`
+	Depth  int    `json:"depth,omitempty"`
+	Auth   string `json:"auth"`
+}
+
+// buildPlan tells what would happen to every target, looking at what's already on disk
+func buildPlan(auth *authenticator, targets []cloneTarget) []planEntry {
+	plan := make([]planEntry, len(targets))
+	for i, t := range targets {
+		entry := planEntry{
+			Repo:   t.Name,
+			URL:    t.URL,
+			Target: t.clonePath(),
+			Action: actionClone,
+			Mode:   string(t.Options.Mode.OrDefault()),
+			Filter: string(t.Options.Filter),
+			Branch: t.Options.Branch,
+			Depth:  t.Options.Depth,
+			Auth:   string(auth.kind(t.Auth, t.URL)),
+		}
+		if t.Options.Cache.Enabled() {
+			// clones made from the cache are never shallow, whatever the depth, see cloneFromMirror
+			entry.Cache, entry.Depth = string(t.Options.Cache), 0
+		}
+		if _, err := os.Stat(t.clonePath()); err == nil {
+			entry.Update = string(t.Options.Update.OrDefault())
+			entry.Action = actionUpdate
+			if t.Options.Update.OrDefault() == UpdateSkip {
+				entry.Action = actionSkip
+			}
+		}
+		plan[i] = entry
+	}
+	return plan
+}
+
+// writePlan prints the plan in the given format
+func writePlan(out io.Writer, format string, plan []planEntry) error {
+	if format == outputJSON {
+		return writeJSON(out, plan)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "REPO\tTARGET\tACTION\tMODE\tBRANCH\tDEPTH\tAUTH")
+	for _, e := range plan {
+		branch, depth, mode := e.Branch, "full", e.Mode
+		if branch == "" {
+			branch = "default"
+		}
+		if e.Depth > 0 {
+			depth = fmt.Sprint(e.Depth)
+		}
+		if e.Filter != "" {
+			mode += " (" + e.Filter + ")"
+		}
+		if e.Cache != "" {
+			mode += " (cached, " + e.Cache + ")"
+		}
+		action := e.Action
+		if e.Action == actionUpdate {
+			action += " (" + e.Update + ")"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Repo, e.Target, action, mode, branch, depth, e.Auth)
+	}
+	return w.Flush()
+}
+
+// writeJSON prints v as indented JSON, so it diffs well
+func writeJSON(out io.Writer, v any) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// validateOutputFormat checks the --output flag
+func validateOutputFormat(format string) error {
+	if format != outputTable && format != outputJSON {
+		return fmt.Errorf("unknown output format '%s', valid values are %s and %s", format, outputTable, outputJSON)
+	}
+	return nil
+}
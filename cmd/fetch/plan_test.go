@@ -0,0 +1,73 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+)
+
+func TestBuildPlan_Actions(t *testing.T) {
+	workspace := t.TempDir()
+	for _, name := range []string{"skipped", "updated"} {
+		if err := os.Mkdir(filepath.Join(workspace, name), 0755); err != nil {
+			t.Fatalf("Failed to create an existing clone: %v", err)
+		}
+	}
+
+	targets := []cloneTarget{
+		{Name: "new", URL: "git@github.com:acme/new.git", Dir: workspace, Options: &CloneOptions{Depth: 1}},
+		{Name: "skipped", URL: "https://github.com/acme/skipped.git", Dir: workspace, Options: &CloneOptions{}},
+		{Name: "updated", URL: "https://github.com/acme/updated.git", Dir: workspace,
+			Options: &CloneOptions{Update: UpdatePull}, Auth: &AuthConfig{OAuthToken: "token"}},
+	}
+
+	plan := buildPlan(newAuthenticator(nil), targets)
+
+	expected := []struct{ action, auth string }{
+		{actionClone, string(authSSHAgent)},
+		{actionSkip, string(authNone)},
+		{actionUpdate, string(authOAuthToken)},
+	}
+	for i, e := range expected {
+		if plan[i].Action != e.action || plan[i].Auth != e.auth {
+			t.Errorf("plan for %s = %s with %s auth, expected %s with %s auth",
+				plan[i].Repo, plan[i].Action, plan[i].Auth, e.action, e.auth)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writePlan(&buf, outputJSON, plan); err != nil {
+		t.Fatalf("writePlan() returned an error: %v", err)
+	}
+	var decoded []planEntry
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("writePlan() did not write valid JSON: %v", err)
+	}
+	if len(decoded) != len(plan) || decoded[2].Update != string(UpdatePull) {
+		t.Errorf("the JSON plan doesn't match the plan: %s", buf.String())
+	}
+}
+
+func TestBuildPlan_CachedDepth(t *testing.T) {
+	targets := []cloneTarget{
+		{Name: "api", URL: "git@github.com:acme/api.git", Dir: t.TempDir(), Options: &CloneOptions{Depth: 1, Cache: CacheAlternates}},
+	}
+
+	plan := buildPlan(newAuthenticator(nil), targets)
+
+	if plan[0].Depth != 0 || plan[0].Cache != string(CacheAlternates) {
+		t.Errorf("plan for a cached target has the depth %d, expected the full history of the mirror", plan[0].Depth)
+	}
+	var buf bytes.Buffer
+	if err := writePlan(&buf, outputTable, plan); err != nil {
+		t.Fatalf("writePlan() returned an error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "full") {
+		t.Errorf("the table does not show the full depth:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,151 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/florinutz/git-intel/src/progress"
+	"github.com/florinutz/git-intel/src/retry"
+)
+
+// defaultJobs is the number of clone workers used when neither --jobs nor the concurrency config are set
+const defaultJobs = 4
+
+// errNotStarted is the outcome of the targets left over when the run is interrupted
+var errNotStarted = errors.New("not started, the run was interrupted")
+
+// cloneResult is the outcome of processing a single clone target
+type cloneResult struct {
+	Target  cloneTarget
+	Err     error
+	Retries int // how many times the clone or update was tried again after a transient failure
+
+	Submodules []submoduleFailure // the submodules that could not be cloned, which doesn't fail the target
+}
+
+// cloneFunc clones or updates a single target, see cloneRepo
+type cloneFunc func(ctx context.Context, out io.Writer, target cloneTarget, task *progress.Task) error
+
+// cloneAll fans the targets out to a bounded pool of clone workers, showing their progress on display.
+// A failing target doesn't stop the others, every outcome is returned in the order of the targets.
+// The targets using the cache are cloned from mirrors, see cloneRepo.
+// Transient failures are retried as policy tells, a nil policy tries every target once.
+// Once ctx is canceled no new target is started, the clones in flight are interrupted and rolled back.
+func cloneAll(ctx context.Context, display *progress.Display, auth *authenticator, mirrors *mirrorCache, policy *retry.Policy, targets []cloneTarget, jobs int) []cloneResult {
+	return runPool(ctx, display, policy, targets, jobs, func(ctx context.Context, out io.Writer, target cloneTarget, task *progress.Task) error {
+		return cloneRepo(ctx, out, auth, mirrors, target, task)
+	})
+}
+
+// runPool runs clone on every target, at most jobs at once, the way cloneAll describes
+func runPool(ctx context.Context, display *progress.Display, policy *retry.Policy, targets []cloneTarget, jobs int, clone cloneFunc) []cloneResult {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	display.SetTotal(len(targets))
+	results := make([]cloneResult, len(targets))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < jobs && w < len(targets); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if ctx.Err() != nil {
+					results[i] = cloneResult{Target: targets[i], Err: errNotStarted}
+					continue
+				}
+				task := display.Start(targets[i].Name)
+				retries, err := policy.Do(ctx, func() error {
+					return clone(ctx, display, targets[i], task)
+				}, func(err error, wait time.Duration) {
+					_, _ = fmt.Fprintf(display, "retrying %s in %s: %v\n", targets[i].URL, wait.Round(time.Millisecond), err)
+				})
+				result := cloneResult{Target: targets[i], Err: err, Retries: retries}
+				var submodules *submoduleError
+				if errors.As(err, &submodules) {
+					result.Err, result.Submodules = nil, submodules.Failures
+				}
+				task.Done(result.Err)
+				results[i] = result
+			}
+		}()
+	}
+
+	dispatched := 0
+dispatch:
+	for ; dispatched < len(targets); dispatched++ {
+		select {
+		case indexes <- dispatched:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i := dispatched; i < len(targets); i++ {
+		results[i] = cloneResult{Target: targets[i], Err: errNotStarted}
+	}
+
+	return results
+}
+
+// report prints the failed targets and returns an error if there were any.
+// The submodules that could not be cloned are listed as well, without making it return an error.
+// It tells how many retries the clones, the updates and the API calls took.
+// After an interruption it also tells how many targets completed and how many were never started.
+func report(out io.Writer, results []cloneResult, apiRetries int) error {
+	var failed []cloneResult
+	notStarted, retries, retried, submodules := 0, 0, 0, 0
+	for _, r := range results {
+		submodules += len(r.Submodules)
+		if r.Retries > 0 {
+			retries += r.Retries
+			retried++
+		}
+		switch {
+		case errors.Is(r.Err, errNotStarted):
+			notStarted++
+		case r.Err != nil:
+			failed = append(failed, r)
+		}
+	}
+	processed := len(results) - notStarted
+
+	_, _ = fmt.Fprintf(out, "\n%d repositories processed, %d failed\n", processed, len(failed))
+	for _, r := range failed {
+		if r.Retries > 0 {
+			_, _ = fmt.Fprintf(out, "  %s: %v (after %d retries)\n", r.Target.clonePath(), r.Err, r.Retries)
+			continue
+		}
+		_, _ = fmt.Fprintf(out, "  %s: %v\n", r.Target.clonePath(), r.Err)
+	}
+	if submodules > 0 {
+		_, _ = fmt.Fprintf(out, "%d submodules could not be cloned\n", submodules)
+		for _, r := range results {
+			for _, f := range r.Submodules {
+				_, _ = fmt.Fprintf(out, "  %s (%s): %v\n", filepath.Join(r.Target.clonePath(), f.Path), f.URL, f.Err)
+			}
+		}
+	}
+	if retries > 0 || apiRetries > 0 {
+		_, _ = fmt.Fprintf(out, "retries: %d for %d repositories, %d for API calls\n", retries, retried, apiRetries)
+	}
+
+	if notStarted > 0 {
+		_, _ = fmt.Fprintf(out, "interrupted: %d repositories completed, %d not started\n", processed-len(failed), notStarted)
+		return fmt.Errorf("interrupted after %d of %d repositories", processed, len(results))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d repositories failed", len(failed), len(results))
+	}
+	return nil
+}
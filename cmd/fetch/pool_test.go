@@ -0,0 +1,276 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/progress"
+	"github.com/florinutz/git-intel/src/retry"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// newLocalRemote creates a repository with a single commit that can be cloned through the file transport
+func newLocalRemote(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, false); err != nil {
+		t.Fatalf("Failed to init the remote repository: %v", err)
+	}
+	commitFile(t, dir, "README.md", "remote\n")
+
+	return dir
+}
+
+// commitFile writes a file in the worktree at dir and commits it
+func commitFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatalf("Failed to open the repository: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get the worktree: %v", err)
+	}
+	if _, err := wt.Add(name); err != nil {
+		t.Fatalf("Failed to stage %s: %v", name, err)
+	}
+	if _, err := wt.Commit("update "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	}); err != nil {
+		t.Fatalf("Failed to commit %s: %v", name, err)
+	}
+}
+
+func TestCloneAll_CollectsFailures(t *testing.T) {
+	remote := newLocalRemote(t)
+	workspace := t.TempDir()
+
+	targets := []cloneTarget{
+		{Name: "good", URL: remote, Dir: workspace, Options: &CloneOptions{}},
+		{Name: "bad", URL: filepath.Join(workspace, "missing"), Dir: workspace, Options: &CloneOptions{}},
+		{Name: "good-too", URL: remote, Dir: workspace, Options: &CloneOptions{}},
+	}
+
+	results := cloneAll(context.Background(), progress.New(io.Discard, 0), newAuthenticator(nil), nil, nil, targets, 2)
+	if len(results) != len(targets) {
+		t.Fatalf("cloneAll() returned %d results, expected %d", len(results), len(targets))
+	}
+
+	for i, r := range results {
+		if r.Target.Name != targets[i].Name {
+			t.Errorf("result %d is for %s, expected %s", i, r.Target.Name, targets[i].Name)
+		}
+	}
+	if results[0].Err != nil || results[2].Err != nil {
+		t.Errorf("cloneAll() failed for valid targets: %v, %v", results[0].Err, results[2].Err)
+	}
+	if results[1].Err == nil {
+		t.Errorf("cloneAll() did not report the failure of the missing remote")
+	}
+
+	if _, err := os.Stat(filepath.Join(workspace, "good-too", "README.md")); err != nil {
+		t.Errorf("the second valid target was not cloned: %v", err)
+	}
+
+	if err := report(io.Discard, results, 0); err == nil {
+		t.Errorf("report() did not return an error for a failed target")
+	}
+}
+
+func TestCloneAll_Interrupted(t *testing.T) {
+	remote := newLocalRemote(t)
+	workspace := t.TempDir()
+
+	targets := []cloneTarget{
+		{Name: "first", URL: remote, Dir: workspace, Options: &CloneOptions{}},
+		{Name: "second", URL: remote, Dir: workspace, Options: &CloneOptions{}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := cloneAll(ctx, progress.New(io.Discard, 0), newAuthenticator(nil), nil, nil, targets, 1)
+
+	for _, r := range results {
+		if !errors.Is(r.Err, errNotStarted) {
+			t.Errorf("%s was not left out of the interrupted run: %v", r.Target.Name, r.Err)
+		}
+		if _, err := os.Stat(r.Target.clonePath()); !os.IsNotExist(err) {
+			t.Errorf("%s left a directory behind: %v", r.Target.Name, err)
+		}
+	}
+
+	var summary strings.Builder
+	if err := report(&summary, results, 0); err == nil {
+		t.Errorf("report() did not return an error for an interrupted run")
+	}
+	if !strings.Contains(summary.String(), "0 repositories completed, 2 not started") {
+		t.Errorf("report() printed %q", summary.String())
+	}
+}
+
+func TestCloneAll_RetriesTransientFailures(t *testing.T) {
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	targets := []cloneTarget{{Name: "api", URL: server.URL + "/acme/api.git", Dir: t.TempDir(), Options: &CloneOptions{}}}
+	policy := &retry.Policy{Attempts: 3, BaseDelay: time.Millisecond, RetryOn: []retry.Class{retry.Server}}
+	results := cloneAll(context.Background(), progress.New(io.Discard, 0), newAuthenticator(nil), nil, policy, targets, 1)
+
+	if results[0].Err == nil || results[0].Retries != 2 || hits != 3 {
+		t.Errorf("cloneAll() returned %v after %d retries and %d hits, expected to give up after 3 attempts", results[0].Err, results[0].Retries, hits)
+	}
+
+	var summary strings.Builder
+	if err := report(&summary, results, 4); err == nil {
+		t.Errorf("report() did not return an error for a failed target")
+	}
+	if !strings.Contains(summary.String(), "(after 2 retries)") || !strings.Contains(summary.String(), "retries: 2 for 1 repositories, 4 for API calls") {
+		t.Errorf("report() printed %q", summary.String())
+	}
+}
+
+func TestCloneRepo_RollsBackInterruptedClones(t *testing.T) {
+	remote := newLocalRemote(t)
+	target := cloneTarget{Name: "api", URL: remote, Dir: t.TempDir(), Options: &CloneOptions{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := cloneRepo(ctx, io.Discard, newAuthenticator(nil), nil, target, nil); err == nil {
+		t.Fatalf("cloneRepo() did not fail with a canceled context")
+	}
+	if entries, err := os.ReadDir(target.Dir); err != nil || len(entries) != 0 {
+		t.Errorf("the interrupted clone left %v behind: %v", entries, err)
+	}
+}
+
+// countingClone is a clone func that records how many clones run at once. Every clone blocks until release is closed.
+type countingClone struct {
+	mu      sync.Mutex
+	running int
+	max     int
+	started chan string
+	release chan struct{}
+}
+
+func newCountingClone(targets int) *countingClone {
+	return &countingClone{started: make(chan string, targets), release: make(chan struct{})}
+}
+
+func (c *countingClone) clone(ctx context.Context, _ io.Writer, target cloneTarget, _ *progress.Task) error {
+	c.mu.Lock()
+	c.running++
+	c.max = max(c.max, c.running)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.running--
+		c.mu.Unlock()
+	}()
+
+	c.started <- target.Name
+	<-c.release
+	return nil
+}
+
+// awaitStarts waits for n clones to start, then makes sure no other one does for a while
+func (c *countingClone) awaitStarts(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-c.started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d clones started, expected %d", i, n)
+		}
+	}
+	select {
+	case name := <-c.started:
+		t.Errorf("%s started while %d clones were running", name, n)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func newPoolTargets(n int) []cloneTarget {
+	targets := make([]cloneTarget, n)
+	for i := range targets {
+		targets[i] = cloneTarget{Name: fmt.Sprintf("repo-%d", i), Options: &CloneOptions{}}
+	}
+	return targets
+}
+
+func TestRunPool_BoundsConcurrency(t *testing.T) {
+	const jobs = 3
+	targets := newPoolTargets(10)
+	fake := newCountingClone(len(targets))
+
+	done := make(chan []cloneResult)
+	go func() {
+		done <- runPool(context.Background(), progress.New(io.Discard, 0), nil, targets, jobs, fake.clone)
+	}()
+	fake.awaitStarts(t, jobs)
+	close(fake.release)
+	results := <-done
+
+	if fake.max != jobs {
+		t.Errorf("runPool() ran %d clones at once, expected %d", fake.max, jobs)
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("%s failed: %v", r.Target.Name, r.Err)
+		}
+	}
+	if n := len(fake.started); n != len(targets)-jobs {
+		t.Errorf("runPool() started %d clones after the first ones, expected %d", n, len(targets)-jobs)
+	}
+}
+
+func TestRunPool_CancelStopsNewStarts(t *testing.T) {
+	const jobs = 2
+	targets := newPoolTargets(6)
+	fake := newCountingClone(len(targets))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan []cloneResult)
+	go func() {
+		done <- runPool(ctx, progress.New(io.Discard, 0), nil, targets, jobs, fake.clone)
+	}()
+	fake.awaitStarts(t, jobs)
+	cancel()
+	close(fake.release)
+	results := <-done
+
+	if n := len(fake.started); n != 0 {
+		t.Errorf("runPool() started %d clones after the cancellation", n)
+	}
+	notStarted := 0
+	for _, r := range results {
+		if errors.Is(r.Err, errNotStarted) {
+			notStarted++
+		}
+	}
+	if notStarted != len(targets)-jobs {
+		t.Errorf("runPool() left %d targets out, expected %d", notStarted, len(targets)-jobs)
+	}
+}
@@ -0,0 +1,38 @@
+package fetch
+
+import (
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/retry"
+)
+
+// newRetryPolicy returns the retry policy of the config, the default one for the settings it leaves out
+func newRetryPolicy(config *RetryConfig) (*retry.Policy, error) {
+	policy := retry.NewPolicy()
+	if config == nil {
+		return policy, nil
+	}
+
+	if config.Attempts != 0 {
+		policy.Attempts = config.Attempts
+	}
+	if config.BaseDelay != 0 {
+		policy.BaseDelay = config.BaseDelay
+	}
+	if config.MaxDelay != 0 {
+		policy.MaxDelay = config.MaxDelay
+	}
+	if config.Jitter != nil {
+		policy.Jitter = *config.Jitter
+	}
+	if len(config.RetryOn) > 0 {
+		policy.RetryOn = make([]retry.Class, len(config.RetryOn))
+		for i, c := range config.RetryOn {
+			policy.RetryOn[i] = retry.Class(c)
+		}
+	}
+
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
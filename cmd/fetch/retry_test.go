@@ -0,0 +1,31 @@
+package fetch
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/retry"
+)
+
+func TestNewRetryPolicy(t *testing.T) {
+	noJitter := 0.0
+	policy, err := newRetryPolicy(&RetryConfig{Attempts: 5, MaxDelay: time.Minute, Jitter: &noJitter, RetryOn: []string{"network", "rate_limit"}})
+	if err != nil {
+		t.Fatalf("newRetryPolicy() returned an error: %v", err)
+	}
+	if policy.Attempts != 5 || policy.BaseDelay != retry.DefaultBaseDelay || policy.MaxDelay != time.Minute || policy.Jitter != 0 {
+		t.Errorf("newRetryPolicy() = %+v", policy)
+	}
+	if !slices.Equal(policy.RetryOn, []retry.Class{retry.Network, retry.RateLimit}) {
+		t.Errorf("newRetryPolicy() did not retry the configured classes: %v", policy.RetryOn)
+	}
+
+	if _, err := newRetryPolicy(&RetryConfig{RetryOn: []string{"everything"}}); err == nil {
+		t.Errorf("newRetryPolicy() did not return an error for an unknown class")
+	}
+	if _, err := newRetryPolicy(&RetryConfig{Attempts: -1}); err == nil {
+		t.Errorf("newRetryPolicy() did not return an error for negative attempts")
+	}
+}
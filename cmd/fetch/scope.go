@@ -0,0 +1,90 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/resolve"
+)
+
+// listOrgRepos lists the repositories an org entry is scoped to: all the repositories of the owner by default,
+// or only the ones its teams can access and the ones tagged with its topics.
+// A repository has to match both selectors when both are set.
+func listOrgRepos(ctx context.Context, provider resolve.Provider, org GithubOrgConfig, opts resolve.ListOptions) ([]*resolve.Repository, error) {
+	if len(org.Teams) == 0 && len(org.Topics) == 0 {
+		return provider.ListRepos(ctx, org.Name, opts)
+	}
+
+	if len(org.Teams) > 0 {
+		repos, err := listTeamRepos(ctx, provider, org)
+		if err != nil {
+			return nil, err
+		}
+		return slices.DeleteFunc(repos, func(repo *resolve.Repository) bool { return !matchesTopics(repo, org) }), nil
+	}
+
+	searcher, ok := provider.(resolve.TopicSearcher)
+	if !ok || org.Name == resolve.Me {
+		// no server-side lookup, the topics are matched on the full listing
+		repos, err := provider.ListRepos(ctx, org.Name, opts)
+		if err != nil {
+			return nil, err
+		}
+		return slices.DeleteFunc(repos, func(repo *resolve.Repository) bool { return !matchesTopics(repo, org) }), nil
+	}
+
+	if org.TopicsMatch.OrDefault() == TopicsMatchAll {
+		return searcher.SearchByTopics(ctx, org.Name, org.Topics)
+	}
+	var tagged []*resolve.Repository
+	for _, topic := range org.Topics {
+		repos, err := searcher.SearchByTopics(ctx, org.Name, []string{topic})
+		if err != nil {
+			return nil, err
+		}
+		tagged = appendNew(tagged, repos)
+	}
+	return tagged, nil
+}
+
+// listTeamRepos lists the repositories any of the org's teams can access
+func listTeamRepos(ctx context.Context, provider resolve.Provider, org GithubOrgConfig) ([]*resolve.Repository, error) {
+	lister, ok := provider.(resolve.TeamLister)
+	if !ok {
+		return nil, fmt.Errorf("org '%s': host %s has no teams", org.Name, org.HostName())
+	}
+
+	var repos []*resolve.Repository
+	for _, team := range org.Teams {
+		teamRepos, err := lister.ListTeamRepos(ctx, org.Name, team)
+		if err != nil {
+			return nil, err
+		}
+		repos = appendNew(repos, teamRepos)
+	}
+	return repos, nil
+}
+
+// matchesTopics tells if a repository is tagged with the org's topics, any or all of them depending on topics_match
+func matchesTopics(repo *resolve.Repository, org GithubOrgConfig) bool {
+	if len(org.Topics) == 0 {
+		return true
+	}
+	tagged := func(topic string) bool { return containsFold(repo.Topics, topic) }
+	if org.TopicsMatch.OrDefault() == TopicsMatchAll {
+		return !slices.ContainsFunc(org.Topics, func(topic string) bool { return !tagged(topic) })
+	}
+	return slices.ContainsFunc(org.Topics, tagged)
+}
+
+// appendNew appends the repositories that aren't in the list yet, as several teams or topics can share repositories
+func appendNew(list, repos []*resolve.Repository) []*resolve.Repository {
+	for _, repo := range repos {
+		if !slices.ContainsFunc(list, func(r *resolve.Repository) bool { return r.Ref() == repo.Ref() }) {
+			list = append(list, repo)
+		}
+	}
+	return list
+}
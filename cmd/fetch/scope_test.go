@@ -0,0 +1,115 @@
+package fetch
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/resolve"
+)
+
+// scopedProvider serves a fixed org whose repositories belong to teams and carry topics
+type scopedProvider struct {
+	repos    []*resolve.Repository
+	teams    map[string][]string
+	searches [][]string // the topics of every search, in order
+}
+
+func (p *scopedProvider) ListRepos(context.Context, string, resolve.ListOptions) ([]*resolve.Repository, error) {
+	return p.repos, nil
+}
+
+func (p *scopedProvider) GetRepo(context.Context, string, string) (*resolve.Repository, error) {
+	return nil, nil
+}
+
+func (p *scopedProvider) CloneURLs(string, string) resolve.CloneURLs {
+	return resolve.CloneURLs{}
+}
+
+func (p *scopedProvider) ListTeamRepos(_ context.Context, _, team string) ([]*resolve.Repository, error) {
+	var repos []*resolve.Repository
+	for _, repo := range p.repos {
+		if slices.Contains(p.teams[team], repo.Name) {
+			repos = append(repos, repo)
+		}
+	}
+	return repos, nil
+}
+
+func (p *scopedProvider) SearchByTopics(_ context.Context, _ string, topics []string) ([]*resolve.Repository, error) {
+	p.searches = append(p.searches, topics)
+	var repos []*resolve.Repository
+	for _, repo := range p.repos {
+		if !slices.ContainsFunc(topics, func(topic string) bool { return !slices.Contains(repo.Topics, topic) }) {
+			repos = append(repos, repo)
+		}
+	}
+	return repos, nil
+}
+
+func newScopedProvider() *scopedProvider {
+	repo := func(name string, topics ...string) *resolve.Repository {
+		return &resolve.Repository{Host: "github.com", Owner: "acme", Name: name, Topics: topics}
+	}
+	return &scopedProvider{
+		repos: []*resolve.Repository{
+			repo("api", "backend", "go"),
+			repo("web", "frontend"),
+			repo("infra", "backend", "terraform"),
+			repo("docs"),
+		},
+		teams: map[string][]string{
+			"platform": {"api", "infra"},
+			"design":   {"web", "api"},
+		},
+	}
+}
+
+func TestListOrgRepos(t *testing.T) {
+	tests := []struct {
+		name     string
+		org      GithubOrgConfig
+		expected []string
+	}{
+		{"no selectors", GithubOrgConfig{}, []string{"api", "web", "infra", "docs"}},
+		{"teams", GithubOrgConfig{Teams: []string{"platform", "design"}}, []string{"api", "infra", "web"}},
+		{"any topic", GithubOrgConfig{Topics: []string{"go", "frontend"}}, []string{"api", "web"}},
+		{"all topics", GithubOrgConfig{Topics: []string{"backend", "go"}, TopicsMatch: TopicsMatchAll}, []string{"api"}},
+		{"teams and topics", GithubOrgConfig{Teams: []string{"design"}, Topics: []string{"backend"}}, []string{"api"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.org.Name = "acme"
+			repos, err := listOrgRepos(context.Background(), newScopedProvider(), tt.org, resolve.ListOptions{})
+			if err != nil {
+				t.Fatalf("listOrgRepos() returned an error: %v", err)
+			}
+			if names := namesOf(repos); !slices.Equal(names, tt.expected) {
+				t.Errorf("listOrgRepos() = %v, expected %v", names, tt.expected)
+			}
+		})
+	}
+}
+
+func TestListOrgRepos_TopicSearches(t *testing.T) {
+	provider := newScopedProvider()
+	org := GithubOrgConfig{Name: "acme", Topics: []string{"backend", "go"}}
+	if _, err := listOrgRepos(context.Background(), provider, org, resolve.ListOptions{}); err != nil {
+		t.Fatalf("listOrgRepos() returned an error: %v", err)
+	}
+	if len(provider.searches) != 2 {
+		t.Errorf("expected a search per topic when any topic matches, got %v", provider.searches)
+	}
+
+	provider = newScopedProvider()
+	org.TopicsMatch = TopicsMatchAll
+	if _, err := listOrgRepos(context.Background(), provider, org, resolve.ListOptions{}); err != nil {
+		t.Fatalf("listOrgRepos() returned an error: %v", err)
+	}
+	if len(provider.searches) != 1 {
+		t.Errorf("expected a single search when all topics have to match, got %v", provider.searches)
+	}
+}
@@ -0,0 +1,156 @@
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/resolve"
+)
+
+// regexPrefix marks the include and exclude patterns that are regular expressions instead of globs
+const regexPrefix = "re:"
+
+// repoPattern matches repository names either by glob or by regular expression
+type repoPattern struct {
+	raw string
+	re  *regexp.Regexp // nil for globs
+}
+
+func newRepoPattern(raw string) (repoPattern, error) {
+	if expr, ok := strings.CutPrefix(raw, regexPrefix); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return repoPattern{}, fmt.Errorf("invalid regex '%s': %w", raw, err)
+		}
+		return repoPattern{raw: raw, re: re}, nil
+	}
+	if _, err := path.Match(raw, ""); err != nil {
+		return repoPattern{}, fmt.Errorf("invalid glob '%s': %w", raw, err)
+	}
+	return repoPattern{raw: raw}, nil
+}
+
+func (p repoPattern) match(name string) bool {
+	if p.re != nil {
+		return p.re.MatchString(name)
+	}
+	matched, _ := path.Match(p.raw, name)
+	return matched
+}
+
+// repoMatcher applies an org's include_repos and exclude_repos lists
+type repoMatcher struct {
+	include []repoPattern
+	exclude []repoPattern
+}
+
+// newRepoMatcher compiles the include and exclude patterns of an org
+func newRepoMatcher(org GithubOrgConfig) (*repoMatcher, error) {
+	m := &repoMatcher{}
+	for _, raw := range org.IncludeRepos {
+		p, err := newRepoPattern(raw)
+		if err != nil {
+			return nil, fmt.Errorf("include_repos: %w", err)
+		}
+		m.include = append(m.include, p)
+	}
+	for _, raw := range org.ExcludeRepos {
+		p, err := newRepoPattern(raw)
+		if err != nil {
+			return nil, fmt.Errorf("exclude_repos: %w", err)
+		}
+		m.exclude = append(m.exclude, p)
+	}
+	return m, nil
+}
+
+// decide tells if a repository name is selected and which rule decided it.
+// Exclusions win over inclusions, and an empty include list includes everything.
+func (m *repoMatcher) decide(name string) (bool, string) {
+	for _, p := range m.exclude {
+		if p.match(name) {
+			return false, fmt.Sprintf("exclude_repos '%s'", p.raw)
+		}
+	}
+	if len(m.include) == 0 {
+		return true, "no include_repos, all included"
+	}
+	for _, p := range m.include {
+		if p.match(name) {
+			return true, fmt.Sprintf("include_repos '%s'", p.raw)
+		}
+	}
+	return false, "matches no include_repos"
+}
+
+// selection is the decision taken about a single repository
+type selection struct {
+	Source   string `json:"source"` // the config entry the repository comes from
+	Repo     string `json:"repo"`
+	Included bool   `json:"included"`
+	Rule     string `json:"rule"` // the rule that included or excluded the repository
+}
+
+// selectOrgRepos applies the include and exclude rules, the filter, the order and the limit of an org,
+// in this order, and records why each listed repository was kept or dropped.
+func selectOrgRepos(
+	org GithubOrgConfig,
+	repos []*resolve.Repository,
+	matcher *repoMatcher,
+	filter *repoFilter,
+	order *repoOrder,
+) ([]*resolve.Repository, []selection) {
+	source := "org " + org.Name
+	var kept []*resolve.Repository
+	rules := make(map[*resolve.Repository]string)
+	var decisions []selection
+
+	for _, repo := range repos {
+		included, rule := matcher.decide(repo.Name)
+		if included {
+			if reason := filter.reject(repo); reason != "" {
+				included, rule = false, "repo_filter: "+reason
+			}
+		}
+		if !included {
+			decisions = append(decisions, selection{Source: source, Repo: repo.Name, Rule: rule})
+			continue
+		}
+		kept = append(kept, repo)
+		rules[repo] = rule
+	}
+
+	order.sort(kept)
+	limited := limitRepos(kept, org.RepoLimit)
+	for i, repo := range kept {
+		if i >= len(limited) {
+			rules[repo] = fmt.Sprintf("over repo_limit %d", org.RepoLimit)
+		}
+		decisions = append(decisions, selection{Source: source, Repo: repo.Name, Included: i < len(limited), Rule: rules[repo]})
+	}
+
+	return limited, decisions
+}
+
+// writeSelections prints the decisions taken about every repository in the given format
+func writeSelections(out io.Writer, format string, decisions []selection) error {
+	if format == outputJSON {
+		return writeJSON(out, decisions)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SOURCE\tREPO\tDECISION\tRULE")
+	for _, d := range decisions {
+		decision := "exclude"
+		if d.Included {
+			decision = "include"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Source, d.Repo, decision, d.Rule)
+	}
+	return w.Flush()
+}
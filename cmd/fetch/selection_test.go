@@ -0,0 +1,90 @@
+package fetch
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/resolve"
+)
+
+func TestRepoMatcher_Decide(t *testing.T) {
+	matcher, err := newRepoMatcher(GithubOrgConfig{
+		IncludeRepos: []string{"api-*", "re:^(web|mobile)-app$"},
+		ExcludeRepos: []string{"api-legacy-*", "re:-old$"},
+	})
+	if err != nil {
+		t.Fatalf("newRepoMatcher() returned an error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		included bool
+		rule     string
+	}{
+		{"api-users", true, "include_repos 'api-*'"},
+		{"web-app", true, "include_repos 're:^(web|mobile)-app$'"},
+		{"api-legacy-billing", false, "exclude_repos 'api-legacy-*'"},
+		{"api-users-old", false, "exclude_repos 're:-old$'"},
+		{"desktop-app", false, "matches no include_repos"},
+	}
+
+	for _, tt := range tests {
+		included, rule := matcher.decide(tt.name)
+		if included != tt.included || rule != tt.rule {
+			t.Errorf("decide(%q) = %t, %q, expected %t, %q", tt.name, included, rule, tt.included, tt.rule)
+		}
+	}
+}
+
+func TestNewRepoMatcher_InvalidPatterns(t *testing.T) {
+	for _, org := range []GithubOrgConfig{
+		{ExcludeRepos: []string{"re:("}},
+		{IncludeRepos: []string{"[a-"}},
+	} {
+		if _, err := newRepoMatcher(org); err == nil {
+			t.Errorf("newRepoMatcher(%+v) did not return an error", org)
+		}
+	}
+}
+
+func TestSelectOrgRepos_RecordsEveryDecision(t *testing.T) {
+	org := GithubOrgConfig{
+		Name:         "acme",
+		ExcludeRepos: []string{"legacy-*"},
+		RepoFilter:   &RepoFilterConfig{Archived: ptr(false)},
+		RepoOrder:    &RepoOrderConfig{Field: "stars"},
+		RepoLimit:    1,
+	}
+	repos := []*resolve.Repository{
+		{Name: "legacy-api", Stars: 100},
+		{Name: "archived", Archived: true},
+		{Name: "popular", Stars: 50},
+		{Name: "unpopular", Stars: 1},
+	}
+
+	matcher, _ := newRepoMatcher(org)
+	filter, _ := newRepoFilter(org.RepoFilter)
+	order, _ := newRepoOrder(org.RepoOrder)
+	kept, decisions := selectOrgRepos(org, repos, matcher, filter, order)
+
+	if len(kept) != 1 || kept[0].Name != "popular" {
+		t.Fatalf("selectOrgRepos() kept %v, expected only popular", namesOf(kept))
+	}
+
+	rules := make(map[string]string)
+	for _, d := range decisions {
+		rules[d.Repo] = d.Rule
+	}
+	expected := map[string]string{
+		"legacy-api": "exclude_repos",
+		"archived":   "repo_filter",
+		"popular":    "no include_repos",
+		"unpopular":  "over repo_limit",
+	}
+	for repo, prefix := range expected {
+		if !strings.HasPrefix(rules[repo], prefix) {
+			t.Errorf("the rule for %s is %q, expected it to start with %q", repo, rules[repo], prefix)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stagingPrefix starts the names of the hidden directories clones are written to before being renamed into place.
+// A directory at the target path is then always a complete clone.
+const stagingPrefix = ".git-intel-staging-"
+
+// newStagingDir creates a hidden staging directory next to the clone path of the target,
+// on the same filesystem so it can be renamed into place
+func newStagingDir(target cloneTarget) (string, error) {
+	dir, err := os.MkdirTemp(target.Dir, stagingPrefix+target.Name+"-")
+	if err != nil {
+		return "", fmt.Errorf("can't create a staging directory for %s: %w", target.clonePath(), err)
+	}
+	return dir, nil
+}
+
+// cleanStaleStaging removes the staging directories that interrupted runs left in the configured paths.
+// Two runs fetching into the same path at once would remove each other's staging directories.
+func cleanStaleStaging(out io.Writer, paths []string) error {
+	for _, path := range paths {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return fmt.Errorf("can't look for stale staging directories in '%s': %w", path, err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || !strings.HasPrefix(entry.Name(), stagingPrefix) {
+				continue
+			}
+			stale := filepath.Join(path, entry.Name())
+			if err := os.RemoveAll(stale); err != nil {
+				return fmt.Errorf("can't remove the stale staging directory '%s': %w", stale, err)
+			}
+			_, _ = fmt.Fprintf(out, "removed the stale staging directory %s\n", stale)
+		}
+	}
+	return nil
+}
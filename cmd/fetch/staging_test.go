@@ -0,0 +1,58 @@
+package fetch
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+)
+
+func TestCleanStaleStaging(t *testing.T) {
+	workspace := t.TempDir()
+	for _, dir := range []string{"api", stagingPrefix + "web-123", filepath.Join(stagingPrefix+"docs-456", ".git")} {
+		if err := os.MkdirAll(filepath.Join(workspace, dir), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+
+	var out strings.Builder
+	if err := cleanStaleStaging(&out, []string{workspace}); err != nil {
+		t.Fatalf("cleanStaleStaging() returned an error: %v", err)
+	}
+
+	entries, err := os.ReadDir(workspace)
+	if err != nil {
+		t.Fatalf("Failed to read the workspace: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "api" {
+		t.Errorf("the workspace holds %v, expected only api", entries)
+	}
+	if strings.Count(out.String(), "removed the stale staging directory") != 2 {
+		t.Errorf("cleanStaleStaging() printed %q", out.String())
+	}
+}
+
+func TestCloneRepo_LeavesNoStagingBehind(t *testing.T) {
+	remote := newLocalRemote(t)
+	workspace := t.TempDir()
+	target := cloneTarget{Name: "api", URL: remote, Dir: workspace, Options: &CloneOptions{}}
+
+	if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+		t.Fatalf("cloneRepo() returned an error: %v", err)
+	}
+
+	entries, err := os.ReadDir(workspace)
+	if err != nil {
+		t.Fatalf("Failed to read the workspace: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "api" {
+		t.Errorf("the workspace holds %v, expected only the clone", entries)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "api", "README.md")); err != nil {
+		t.Errorf("the clone was not moved into place: %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// defaultRecurseDepth is how many levels of nested submodules recurse goes down when recurse_depth isn't set
+const defaultRecurseDepth = int(git.DefaultSubmoduleRecursionDepth)
+
+// submoduleFailure is a submodule that could not be cloned or updated
+type submoduleFailure struct {
+	Path string // inside the clone of the target
+	URL  string // as rewritten by submoduleURL
+	Err  error
+}
+
+// submoduleError is returned for clones and updates that went through, save for some of their submodules.
+// It's reported along with the target instead of failing it.
+type submoduleError struct {
+	Failures []submoduleFailure
+}
+
+func (e *submoduleError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		failures[i] = fmt.Sprintf("%s (%s): %v", f.Path, f.URL, f.Err)
+	}
+	return fmt.Sprintf("%d submodules could not be cloned: %s", len(e.Failures), strings.Join(failures, "; "))
+}
+
+// refreshSubmodules updates the submodules of an existing clone, if recurse is set and the update moved its worktree
+func refreshSubmodules(ctx context.Context, auth *authenticator, target cloneTarget, strategy UpdateStrategy) error {
+	o := target.Options
+	if !o.Recurse || o.Mode.IsBare() || strategy == UpdateFetch {
+		return nil
+	}
+	return updateSubmodules(ctx, auth, target, target.clonePath())
+}
+
+// updateSubmodules initializes and updates the submodules of the clone of a target at dir, then theirs,
+// down to the recurse depth. Submodules are fetched from urls rewritten by submoduleURL, with the auth of submoduleAuth.
+// A submodule that can't be cloned doesn't stop the others, they are all returned in a *submoduleError.
+func updateSubmodules(ctx context.Context, auth *authenticator, target cloneTarget, dir string) error {
+	repo, err := openRepo(dir)
+	if err != nil {
+		return fmt.Errorf("can't open the clone of %s: %w", target.URL, err)
+	}
+	depth := target.Options.RecurseDepth
+	if depth <= 0 {
+		depth = defaultRecurseDepth
+	}
+
+	failures, err := updateSubmodulesOf(ctx, auth, target, repo, target.URL, "", depth)
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return &submoduleError{Failures: failures}
+	}
+	return nil
+}
+
+// updateSubmodulesOf updates the submodules of repo, which lives at prefix inside the clone and was fetched from
+// parentURL, going depth levels down
+func updateSubmodulesOf(ctx context.Context, auth *authenticator, target cloneTarget, repo *git.Repository, parentURL, prefix string, depth int) ([]submoduleFailure, error) {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	submodules, err := wt.Submodules()
+	if err != nil {
+		return nil, fmt.Errorf("can't read the submodules of %s: %w", parentURL, err)
+	}
+	if len(submodules) == 0 {
+		return nil, nil
+	}
+
+	// go-git clones the submodules from the urls registered in the config, so the rewritten ones are registered there
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, fmt.Errorf("can't read the config of %s: %w", parentURL, err)
+	}
+	var failures []submoduleFailure
+	failed := make(map[string]bool)
+	for _, sub := range submodules {
+		c := sub.Config()
+		subURL, err := auth.submoduleURL(parentURL, c.URL)
+		if err != nil {
+			failures = append(failures, submoduleFailure{Path: filepath.Join(prefix, c.Path), URL: c.URL, Err: err})
+			failed[c.Name] = true
+			continue
+		}
+		cfg.Submodules[c.Name] = &config.Submodule{Name: c.Name, Path: c.Path, URL: subURL, Branch: c.Branch}
+	}
+	if err := repo.SetConfig(cfg); err != nil {
+		return nil, fmt.Errorf("can't register the submodules of %s: %w", parentURL, err)
+	}
+
+	// listed again, as initialized with the rewritten urls
+	if submodules, err = wt.Submodules(); err != nil {
+		return nil, fmt.Errorf("can't read the submodules of %s: %w", parentURL, err)
+	}
+	for _, sub := range submodules {
+		c := sub.Config()
+		if failed[c.Name] {
+			continue
+		}
+		subPath := filepath.Join(prefix, c.Path)
+		fail := func(err error) {
+			failures = append(failures, submoduleFailure{Path: subPath, URL: c.URL, Err: err})
+		}
+
+		authMethod, err := auth.method(auth.submoduleAuth(target, c.URL), c.URL)
+		if err != nil {
+			fail(fmt.Errorf("error occurred while preparing auth: %w", err))
+			continue
+		}
+		if err := sub.UpdateContext(ctx, &git.SubmoduleUpdateOptions{Auth: authMethod}); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			fail(err)
+			continue
+		}
+
+		if depth > 1 {
+			subRepo, err := sub.Repository()
+			if err != nil {
+				fail(err)
+				continue
+			}
+			nested, err := updateSubmodulesOf(ctx, auth, target, subRepo, c.URL, subPath, depth-1)
+			if err != nil {
+				return nil, err
+			}
+			failures = append(failures, nested...)
+		}
+	}
+	return failures, nil
+}
+
+// submoduleURL resolves the url of a submodule of the repository fetched from parentURL, so that it's fetched the way
+// the parent is. Relative urls are resolved against the parent url, like git does. Urls on the host of the parent
+// using ssh while the parent uses https, or the other way around, are switched to the protocol of the parent,
+// which the auth is set up for.
+func (a *authenticator) submoduleURL(parentURL, rawURL string) (string, error) {
+	if strings.HasPrefix(rawURL, "./") || strings.HasPrefix(rawURL, "../") {
+		resolved := path.Join(urlPath(parentURL), rawURL)
+		if resolved == ".." || strings.HasPrefix(resolved, "../") {
+			return "", fmt.Errorf("the relative url %s goes above the root of %s", rawURL, parentURL)
+		}
+		return withURLPath(parentURL, resolved), nil
+	}
+
+	mismatched := (isHTTPURL(parentURL) && isSSHURL(rawURL)) || (isSSHURL(parentURL) && isHTTPURL(rawURL))
+	if !mismatched {
+		return rawURL, nil
+	}
+	parent, err := transport.NewEndpoint(parentURL)
+	if err != nil {
+		return "", fmt.Errorf("can't parse the url %s: %w", parentURL, err)
+	}
+	sub, err := transport.NewEndpoint(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("can't parse the url %s: %w", rawURL, err)
+	}
+	if !a.sameHost(parent.Host, sub.Host) {
+		return rawURL, nil
+	}
+	return withURLPath(parentURL, strings.TrimPrefix(sub.Path, "/")), nil
+}
+
+// submoduleAuth returns the auth config a submodule at subURL is fetched with: the one of the target when the submodule
+// lives on the host of the target, none otherwise, so that the credentials of the target never reach another host.
+// Submodules on other hosts go through the token configured for their host, or the ssh agent.
+func (a *authenticator) submoduleAuth(target cloneTarget, subURL string) *AuthConfig {
+	parent, err := transport.NewEndpoint(target.URL)
+	if err != nil {
+		return nil
+	}
+	sub, err := transport.NewEndpoint(subURL)
+	if err != nil || !a.sameHost(parent.Host, sub.Host) {
+		return nil
+	}
+	return target.Auth
+}
+
+// sameHost tells if two url hosts are the same git host, one of them being its ssh host maybe
+func (a *authenticator) sameHost(x, y string) bool {
+	return x == y || (y != "" && a.hosts[x].SSHHost == y) || (x != "" && a.hosts[y].SSHHost == x)
+}
+
+// urlPath returns the repository path of a clone url: the path of urls and scp-like urls, or a local path
+func urlPath(repoURL string) string {
+	if prefix := scpLikeURL.FindString(repoURL); prefix != "" {
+		return strings.TrimPrefix(repoURL, prefix)
+	}
+	if u, err := url.Parse(repoURL); err == nil && strings.Contains(repoURL, "://") {
+		return u.Path
+	}
+	return repoURL
+}
+
+// withURLPath returns repoURL with its repository path, as urlPath returns it, replaced by p
+func withURLPath(repoURL, p string) string {
+	if prefix := scpLikeURL.FindString(repoURL); prefix != "" {
+		return prefix + p
+	}
+	if u, err := url.Parse(repoURL); err == nil && strings.Contains(repoURL, "://") {
+		u.Path, u.RawPath = "/"+strings.TrimPrefix(p, "/"), ""
+		return u.String()
+	}
+	return filepath.FromSlash(p)
+}
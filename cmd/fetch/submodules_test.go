@@ -0,0 +1,210 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/progress"
+	"github.com/florinutz/git-intel/src/resolve"
+	"github.com/go-git/go-git/v5"
+)
+
+// gitCommitEnv lets the git binary commit without a configured identity
+var gitCommitEnv = []string{
+	"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+	"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+}
+
+// newSiblingRemote creates a repository with a single commit in dir, next to the other remotes of a test
+func newSiblingRemote(t *testing.T, dir string) string {
+	t.Helper()
+	if _, err := git.PlainInit(dir, false); err != nil {
+		t.Fatalf("Failed to init %s: %v", dir, err)
+	}
+	commitFile(t, dir, "README.md", filepath.Base(dir)+"\n")
+	return dir
+}
+
+// addSubmodule records a submodule of the repository at dir at the head of the repository at commitOf, then commits it.
+// The submodule doesn't need to exist, commitOf being empty records a made up commit.
+func addSubmodule(t *testing.T, dir, name, url, commitOf string) {
+	t.Helper()
+
+	commit := strings.Repeat("1", 40)
+	if commitOf != "" {
+		repo, err := git.PlainOpen(commitOf)
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", commitOf, err)
+		}
+		head, err := repo.Head()
+		if err != nil {
+			t.Fatalf("Failed to read the head of %s: %v", commitOf, err)
+		}
+		commit = head.Hash().String()
+	}
+
+	for _, args := range [][]string{
+		{"config", "-f", ".gitmodules", "submodule." + name + ".path", name},
+		{"config", "-f", ".gitmodules", "submodule." + name + ".url", url},
+		{"update-index", "--add", "--cacheinfo", "160000," + commit + "," + name},
+		{"add", ".gitmodules"},
+		{"commit", "--quiet", "-m", "submodule " + name},
+	} {
+		if _, err := runGit(context.Background(), dir, gitCommitEnv, nil, args...); err != nil {
+			t.Fatalf("Failed to add the submodule %s: %v", name, err)
+		}
+	}
+}
+
+// newSubmoduleRemotes creates an app remote with two submodules: lib, at the relative url ../lib, which has a leaf
+// submodule of its own, and missing, which points to a repository that doesn't exist
+func newSubmoduleRemotes(t *testing.T) (app, lib string) {
+	t.Helper()
+	if !gitAvailable() {
+		t.Skip("the git binary is not in the PATH")
+	}
+
+	base := t.TempDir()
+	leaf := newSiblingRemote(t, filepath.Join(base, "leaf"))
+	lib = newSiblingRemote(t, filepath.Join(base, "lib"))
+	addSubmodule(t, lib, "leaf", "../leaf", leaf)
+	app = newSiblingRemote(t, filepath.Join(base, "app"))
+	addSubmodule(t, app, "lib", "../lib", lib)
+	addSubmodule(t, app, "missing", filepath.Join(base, "missing"), "")
+	return app, lib
+}
+
+func TestAuthenticator_SubmoduleURL(t *testing.T) {
+	auth := newAuthenticator(map[string]host{
+		"git.corp.example": {HostOptions: resolve.HostOptions{Host: "git.corp.example", SSHHost: "ssh.git.corp.example"}},
+	})
+	tests := []struct {
+		name     string
+		parent   string
+		url      string
+		expected string
+		err      bool
+	}{
+		{name: "relative to https", parent: "https://github.com/acme/api.git", url: "../lib.git", expected: "https://github.com/acme/lib.git"},
+		{name: "relative to scp-like", parent: "git@github.com:acme/api.git", url: "../../other/lib.git", expected: "git@github.com:other/lib.git"},
+		{name: "nested relative", parent: "https://github.com/acme/api.git", url: "./vendor/lib", expected: "https://github.com/acme/api.git/vendor/lib"},
+		{name: "relative to a local path", parent: "/srv/git/api", url: "../lib", expected: "/srv/git/lib"},
+		{name: "relative above the root", parent: "git@github.com:acme/api.git", url: "../../../lib.git", err: true},
+		{name: "ssh under https", parent: "https://github.com/acme/api.git", url: "git@github.com:acme/lib.git", expected: "https://github.com/acme/lib.git"},
+		{name: "https under ssh", parent: "git@github.com:acme/api.git", url: "https://github.com/acme/lib.git", expected: "git@github.com:acme/lib.git"},
+		{name: "ssh host", parent: "https://git.corp.example/acme/api.git", url: "ssh://git@ssh.git.corp.example/acme/lib.git", expected: "https://git.corp.example/acme/lib.git"},
+		{name: "another host", parent: "https://github.com/acme/api.git", url: "git@gitlab.com:acme/lib.git", expected: "git@gitlab.com:acme/lib.git"},
+		{name: "same protocol", parent: "https://github.com/acme/api.git", url: "https://gitlab.com/acme/lib.git", expected: "https://gitlab.com/acme/lib.git"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, err := auth.submoduleURL(tt.parent, tt.url)
+			if tt.err {
+				if err == nil {
+					t.Errorf("submoduleURL() = %q, expected an error", url)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("submoduleURL() returned an error: %v", err)
+			}
+			if url != tt.expected {
+				t.Errorf("submoduleURL() = %q, expected %q", url, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCloneAll_Submodules(t *testing.T) {
+	tests := []struct {
+		name  string
+		depth int
+		leaf  bool
+	}{
+		{name: "default depth", leaf: true},
+		{name: "direct submodules only", depth: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newSubmoduleRemotes(t)
+			target := cloneTarget{Name: "app", URL: app, Dir: t.TempDir(), Options: &CloneOptions{Recurse: true, RecurseDepth: tt.depth}}
+
+			results := cloneAll(context.Background(), progress.New(io.Discard, 0), newAuthenticator(nil), nil, nil, []cloneTarget{target}, 1)
+			if results[0].Err != nil {
+				t.Fatalf("cloneAll() failed the target: %v", results[0].Err)
+			}
+			if len(results[0].Submodules) != 1 || results[0].Submodules[0].Path != "missing" {
+				t.Fatalf("cloneAll() reported %+v, expected the missing submodule", results[0].Submodules)
+			}
+
+			if _, err := os.Stat(filepath.Join(target.clonePath(), "lib", "README.md")); err != nil {
+				t.Errorf("the lib submodule was not checked out: %v", err)
+			}
+			_, err := os.Stat(filepath.Join(target.clonePath(), "lib", "leaf", "README.md"))
+			if tt.leaf != (err == nil) {
+				t.Errorf("the nested leaf submodule is checked out: %v, expected %v", err == nil, tt.leaf)
+			}
+
+			var summary bytes.Buffer
+			if err := report(&summary, results, 0); err != nil {
+				t.Errorf("report() returned an error for a missing submodule: %v", err)
+			}
+			if !strings.Contains(summary.String(), "1 submodules could not be cloned") {
+				t.Errorf("report() printed %q", summary.String())
+			}
+		})
+	}
+}
+
+func TestUpdateRepo_PullUpdatesSubmodules(t *testing.T) {
+	app, lib := newSubmoduleRemotes(t)
+	target := cloneTarget{Name: "app", URL: app, Dir: t.TempDir(), Options: &CloneOptions{Recurse: true, Update: UpdatePull}}
+	if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err == nil {
+		t.Fatalf("cloneRepo() did not report the missing submodule")
+	}
+
+	commitFile(t, lib, "new.txt", "new\n")
+	addSubmodule(t, app, "lib", "../lib", lib)
+
+	err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil)
+	var failures *submoduleError
+	if !errors.As(err, &failures) || len(failures.Failures) != 1 {
+		t.Errorf("cloneRepo() returned %v after the pull, expected the missing submodule only", err)
+	}
+	if _, err := os.Stat(filepath.Join(target.clonePath(), "lib", "new.txt")); err != nil {
+		t.Errorf("the pull did not move the lib submodule to its new commit: %v", err)
+	}
+}
+
+func TestAuthenticator_SubmoduleAuth(t *testing.T) {
+	auth := newAuthenticator(map[string]host{
+		"git.corp.example": {HostOptions: resolve.HostOptions{Host: "git.corp.example", SSHHost: "ssh.git.corp.example"}},
+	})
+	credentials := &AuthConfig{OAuthToken: "secret"}
+	tests := []struct {
+		name   string
+		parent string
+		url    string
+		passed bool
+	}{
+		{name: "same host", parent: "https://github.com/acme/api.git", url: "https://github.com/acme/lib.git", passed: true},
+		{name: "ssh host", parent: "https://git.corp.example/acme/api.git", url: "ssh://git@ssh.git.corp.example/acme/lib.git", passed: true},
+		{name: "another host", parent: "https://github.com/acme/api.git", url: "https://evil.example/acme/lib.git"},
+		{name: "another host over ssh", parent: "git@github.com:acme/api.git", url: "git@gitlab.com:acme/lib.git"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := cloneTarget{Name: "api", URL: tt.parent, Auth: credentials}
+			if cfg := auth.submoduleAuth(target, tt.url); (cfg == credentials) != tt.passed {
+				t.Errorf("submoduleAuth() = %+v, expected the target's credentials: %v", cfg, tt.passed)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/resolve"
+)
+
+// cloneTarget is a single repository that has to end up in a configured path
+type cloneTarget struct {
+	Name    string        // repository name, used as the directory name inside Dir
+	URL     string        // clone URL
+	Dir     string        // the configured path the repository is cloned into
+	Options *CloneOptions // effective clone options, see resolveOptions
+	Auth    *AuthConfig   // effective auth, see resolveOptions
+}
+
+// clonePath returns the directory the repository is cloned into
+func (t cloneTarget) clonePath() string {
+	return filepath.Join(t.Dir, t.Name)
+}
+
+// targetEntry is a config entry of a path along with what applies to the repositories it asks for
+type targetEntry struct {
+	eff *effectiveOptions
+	url func(repo *resolve.Repository) string // the clone url of a repository
+}
+
+// collectTargets walks every configured path and resolves its repos, orgs and queries into clone targets.
+// A repository asked for several times in the same path is only cloned once, with the options of the first entry
+// asking for it, while two different repositories that would be cloned into the same directory, like alice/tools
+// and acme/tools, are an error.
+// Along with the targets it returns the decision taken about every listed repository.
+func collectTargets(ctx context.Context, resolver *resolve.Resolver, config Config) ([]cloneTarget, []selection, error) {
+	var (
+		targets   []cloneTarget
+		decisions []selection
+	)
+
+	for _, path := range config.Paths {
+		var sources []resolve.Source
+		entries := make(map[string]targetEntry)
+		add := func(source resolve.Source, entry targetEntry) {
+			if _, ok := entries[source.Entry]; !ok {
+				entries[source.Entry] = entry
+			}
+			sources = append(sources, source)
+		}
+
+		for i := range path.Repos {
+			repo := &path.Repos[i]
+			eff := resolveOptions(layersFor(config, path, nil, repo)...)
+			url := repoCloneURL(resolver, repo.Url, &eff.Auth)
+			add(resolve.Source{
+				Entry: fmt.Sprintf("path %s, repo %s", path.Path, repo.Url),
+				List: func(context.Context) ([]*resolve.Repository, error) {
+					decisions = append(decisions, selection{Source: "repos", Repo: repo.Url, Included: true, Rule: "listed in repos"})
+					return []*resolve.Repository{configuredRepo(repo.Url)}, nil
+				},
+			}, targetEntry{eff: &eff, url: func(*resolve.Repository) string { return url }})
+		}
+
+		for i := range path.Orgs {
+			org := &path.Orgs[i]
+			eff := resolveOptions(layersFor(config, path, org, nil)...)
+			add(resolve.Source{
+				Entry: fmt.Sprintf("path %s, org %s", path.Path, org.Name),
+				List: func(ctx context.Context) ([]*resolve.Repository, error) {
+					repos, orgDecisions, err := selectOrg(ctx, resolver, *org)
+					decisions = append(decisions, orgDecisions...)
+					return repos, err
+				},
+			}, targetEntry{eff: &eff, url: func(repo *resolve.Repository) string { return orgRepoURL(repo, &eff.Auth) }})
+		}
+
+		for i := range path.Queries {
+			query := &path.Queries[i]
+			eff := resolveOptions(queryLayers(config, path, query)...)
+			add(resolve.Source{
+				Entry: fmt.Sprintf("path %s, query %s", path.Path, query.Query),
+				List: func(ctx context.Context) ([]*resolve.Repository, error) {
+					repos, err := searchQuery(ctx, resolver, *query)
+					for _, repo := range repos {
+						decisions = append(decisions, selection{Source: "query " + query.Query, Repo: repo.FullName, Included: true, Rule: "matches the query"})
+					}
+					return repos, err
+				},
+			}, targetEntry{eff: &eff, url: func(repo *resolve.Repository) string { return orgRepoURL(repo, &eff.Auth) }})
+		}
+
+		repos, err := resolver.Resolve(ctx, sources)
+		if err != nil {
+			return nil, nil, err
+		}
+		cloned := make(map[string]*resolve.Repository) // the repository cloned into every directory
+		for _, repo := range repos {
+			entry := entries[repo.Sources[0]]
+			t := cloneTarget{Name: repo.Name, URL: entry.url(repo), Dir: path.Path, Options: &entry.eff.Clone, Auth: &entry.eff.Auth}
+			// Resolve lists every repository once, another one in the same directory is a different repository
+			if other, ok := cloned[t.clonePath()]; ok {
+				return nil, nil, fmt.Errorf("repos %s and %s would both be cloned into %s, exclude one of them or put them in different paths",
+					repoIdentity(other), repoIdentity(repo), t.clonePath())
+			}
+			cloned[t.clonePath()] = repo
+			targets = append(targets, t)
+		}
+	}
+
+	return targets, decisions, nil
+}
+
+// searchQuery runs a configured search query through the provider of its host
+func searchQuery(ctx context.Context, resolver *resolve.Resolver, query QueryConfig) ([]*resolve.Repository, error) {
+	provider, err := resolver.Provider(query.HostName())
+	if err != nil {
+		return nil, fmt.Errorf("query '%s': %w", query.Query, err)
+	}
+	searcher, ok := provider.(resolve.RepoSearcher)
+	if !ok {
+		return nil, fmt.Errorf("query '%s': host %s has no repository search", query.Query, query.HostName())
+	}
+	return searcher.SearchRepos(ctx, query.Query)
+}
+
+// selectOrg lists the repositories of an org, a user or of "@me" through the provider of its host and applies its selection rules
+func selectOrg(ctx context.Context, resolver *resolve.Resolver, org GithubOrgConfig) ([]*resolve.Repository, []selection, error) {
+	matcher, err := newRepoMatcher(org)
+	if err != nil {
+		return nil, nil, fmt.Errorf("org '%s': %w", org.Name, err)
+	}
+	filter, err := newRepoFilter(org.RepoFilter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("org '%s': %w", org.Name, err)
+	}
+	order, err := newRepoOrder(org.RepoOrder)
+	if err != nil {
+		return nil, nil, fmt.Errorf("org '%s': %w", org.Name, err)
+	}
+
+	provider, err := resolver.Provider(org.HostName())
+	if err != nil {
+		return nil, nil, fmt.Errorf("org '%s': %w", org.Name, err)
+	}
+	listOpts := order.listOptions()
+	listOpts.Affiliation = org.Affiliation
+	repos, err := listOrgRepos(ctx, provider, org, listOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repos, decisions := selectOrgRepos(org, repos, matcher, filter, order)
+	return repos, decisions, nil
+}
+
+// orgRepoURL picks the clone URL matching the auth method: https for credentials, ssh otherwise
+func orgRepoURL(repo *resolve.Repository, auth *AuthConfig) string {
+	if prefersHTTPS(auth) {
+		return repo.CloneURL
+	}
+	return repo.SSHURL
+}
+
+// repoCloneURL returns the url a configured repo is cloned from.
+// Clone urls are used as they are, while shorthands and urls pasted from a browser are turned into
+// the provider's clone url matching the auth method.
+func repoCloneURL(resolver *resolve.Resolver, repoURL string, auth *AuthConfig) string {
+	ref, err := resolve.ParseRepoURL(repoURL)
+	if err != nil || ref.IsOwner() || isSSHURL(repoURL) {
+		return repoURL
+	}
+	if isHTTPURL(repoURL) && strings.TrimSuffix(strings.TrimRight(repoURL, "/"), ".git") == "https://"+ref.String() {
+		return repoURL
+	}
+
+	provider, err := resolver.Provider(ref.Host)
+	if err != nil {
+		return ref.HTTPSURL()
+	}
+	urls := provider.CloneURLs(ref.Owner, ref.Name)
+	if prefersHTTPS(auth) {
+		return urls.HTTPS
+	}
+	return urls.SSH
+}
+
+// prefersHTTPS tells if the auth config holds credentials for https rather than ssh
+func prefersHTTPS(auth *AuthConfig) bool {
+	return auth != nil && auth.SSHKey == "" && (auth.OAuthToken != "" || auth.Password != "")
+}
+
+// configuredRepo describes a repository listed in repos from its url alone, without calling the API.
+// Local paths get their url as owner, which tells them apart from the other repositories of the same name.
+func configuredRepo(repoURL string) *resolve.Repository {
+	if ref, err := resolve.ParseRepoURL(repoURL); err == nil && !ref.IsOwner() {
+		return &resolve.Repository{Host: ref.Host, Owner: ref.Owner, Name: ref.Name, FullName: ref.Owner + "/" + ref.Name}
+	}
+	return &resolve.Repository{Owner: repoURL, Name: repoNameFromURL(repoURL), FullName: repoURL}
+}
+
+// repoIdentity returns the canonical identity of a repository, host/owner/name whatever the form of its url,
+// or the url itself for local paths
+func repoIdentity(repo *resolve.Repository) string {
+	if repo.Host == "" {
+		return repo.FullName
+	}
+	return strings.ToLower(repo.Ref().String())
+}
+
+// repoNameFromURL extracts the repository name from a clone URL, or from a local path
+func repoNameFromURL(repoURL string) string {
+	if ref, err := resolve.ParseRepoURL(repoURL); err == nil && !ref.IsOwner() {
+		return ref.Name
+	}
+
+	name := strings.TrimSuffix(strings.TrimRight(repoURL, "/"), ".git")
+	if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
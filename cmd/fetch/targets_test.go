@@ -0,0 +1,188 @@
+package fetch
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/resolve"
+)
+
+func TestRepoCloneURL(t *testing.T) {
+	resolver := resolve.NewResolver("", nil)
+	token := &AuthConfig{OAuthToken: "token"}
+
+	tests := []struct {
+		raw      string
+		auth     *AuthConfig
+		expected string
+	}{
+		{"git@github.com:acme/api.git", nil, "git@github.com:acme/api.git"},
+		{"ssh://git@git.corp.example:2222/acme/api.git", nil, "ssh://git@git.corp.example:2222/acme/api.git"},
+		{"https://github.com/acme/api", token, "https://github.com/acme/api"},
+		{"acme/api", nil, "git@github.com:acme/api.git"},
+		{"acme/api", token, "https://github.com/acme/api.git"},
+		{"https://github.com/acme/api/tree/main/cmd", nil, "git@github.com:acme/api.git"},
+		{"https://unknown.example/acme/api/blob/main/go.mod", nil, "https://unknown.example/acme/api.git"},
+		{"/srv/git/api", nil, "/srv/git/api"},
+	}
+
+	for _, tt := range tests {
+		if got := repoCloneURL(resolver, tt.raw, tt.auth); got != tt.expected {
+			t.Errorf("repoCloneURL(%q) = %q, expected %q", tt.raw, got, tt.expected)
+		}
+	}
+}
+
+func TestRepoNameFromURL(t *testing.T) {
+	for raw, expected := range map[string]string{
+		"https://github.com/acme/api.git/":           "api",
+		"git@gitlab.com:acme/platform/web":           "web",
+		"https://github.com/acme/api/tree/main/docs": "api",
+		"/srv/git/worker.git":                        "worker",
+	} {
+		if got := repoNameFromURL(raw); got != expected {
+			t.Errorf("repoNameFromURL(%q) = %q, expected %q", raw, got, expected)
+		}
+	}
+}
+
+// searchProvider answers every search query with the repositories of a scopedProvider
+type searchProvider struct {
+	*scopedProvider
+	queries []string
+}
+
+func (p *searchProvider) SearchRepos(_ context.Context, query string) ([]*resolve.Repository, error) {
+	p.queries = append(p.queries, query)
+	return p.repos, nil
+}
+
+func TestCollectTargets_Queries(t *testing.T) {
+	provider := &searchProvider{scopedProvider: newScopedProvider()}
+	resolver := resolve.NewResolver("", nil)
+	resolver.Register("github.com", provider)
+
+	config := Config{Paths: []Path{{
+		Path:    "/work",
+		Repos:   []RepoConfig{{Url: "git@github.com:acme/api.git"}},
+		Queries: []QueryConfig{{Query: "org:acme language:go", QueryCloneOptions: &CloneOptions{Depth: 1}}},
+	}}}
+
+	targets, decisions, err := collectTargets(context.Background(), resolver, config)
+	if err != nil {
+		t.Fatalf("collectTargets() returned an error: %v", err)
+	}
+
+	if !slices.Equal(provider.queries, []string{"org:acme language:go"}) {
+		t.Errorf("the provider was searched for %v", provider.queries)
+	}
+	// api is configured as a repo as well, it's only cloned once
+	if len(targets) != 4 {
+		t.Fatalf("collectTargets() returned %d targets, expected 4", len(targets))
+	}
+	if web := targets[1]; web.Name != "web" || web.Options.Depth != 1 {
+		t.Errorf("unexpected query target: %+v", web)
+	}
+	if last := decisions[len(decisions)-1]; last.Source != "query org:acme language:go" || !last.Included {
+		t.Errorf("unexpected query decision: %+v", last)
+	}
+
+	config.Paths[0].Queries[0].Host = "gitlab.com"
+	resolver.Register("gitlab.com", newScopedProvider())
+	if _, _, err := collectTargets(context.Background(), resolver, config); err == nil {
+		t.Errorf("collectTargets() searched a host without repository search")
+	}
+}
+
+func TestCollectTargets_SameDirectory(t *testing.T) {
+	resolver := resolve.NewResolver("", nil)
+	resolver.Register("github.com", newScopedProvider())
+
+	tests := []struct {
+		name string
+		repo string
+		err  bool
+	}{
+		{name: "the same repository", repo: "https://github.com/Acme/api"},
+		{name: "another owner", repo: "git@github.com:alice/api.git", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{Paths: []Path{{
+				Path:  "/work",
+				Repos: []RepoConfig{{Url: tt.repo}},
+				Orgs:  []GithubOrgConfig{{Name: "acme"}},
+			}}}
+			targets, _, err := collectTargets(context.Background(), resolver, config)
+			if tt.err {
+				if err == nil || !strings.Contains(err.Error(), "/work/api") {
+					t.Errorf("collectTargets() returned %v, expected an error about /work/api", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("collectTargets() returned an error: %v", err)
+			}
+			if len(targets) != 4 {
+				t.Errorf("collectTargets() returned %d targets, expected 4", len(targets))
+			}
+		})
+	}
+}
+
+func TestCollectTargets(t *testing.T) {
+	resolver := resolve.NewResolver("", nil)
+	resolver.Register("github.com", newScopedProvider())
+
+	tests := []struct {
+		name     string
+		paths    []Path
+		expected []string // the clone paths of the targets
+	}{
+		{
+			name:     "repos",
+			paths:    []Path{{Path: "/work", Repos: []RepoConfig{{Url: "https://github.com/acme/api.git"}, {Url: "/srv/git/tools"}}}},
+			expected: []string{"/work/api", "/work/tools"},
+		},
+		{
+			name:     "org",
+			paths:    []Path{{Path: "/work", Orgs: []GithubOrgConfig{{Name: "acme", ExcludeRepos: []string{"docs"}}}}},
+			expected: []string{"/work/api", "/work/web", "/work/infra"},
+		},
+		{
+			name: "repo listed by an org too",
+			paths: []Path{{
+				Path:  "/work",
+				Repos: []RepoConfig{{Url: "git@github.com:acme/api.git"}},
+				Orgs:  []GithubOrgConfig{{Name: "acme"}},
+			}},
+			expected: []string{"/work/api", "/work/web", "/work/infra", "/work/docs"},
+		},
+		{
+			name: "same repo in two paths",
+			paths: []Path{
+				{Path: "/work", Repos: []RepoConfig{{Url: "git@github.com:acme/api.git"}}},
+				{Path: "/mirror", Repos: []RepoConfig{{Url: "https://github.com/acme/api"}}},
+			},
+			expected: []string{"/work/api", "/mirror/api"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, _, err := collectTargets(context.Background(), resolver, Config{Paths: tt.paths})
+			if err != nil {
+				t.Fatalf("collectTargets() returned an error: %v", err)
+			}
+			var paths []string
+			for _, target := range targets {
+				paths = append(paths, target.clonePath())
+			}
+			if !slices.Equal(paths, tt.expected) {
+				t.Errorf("collectTargets() returned %v, expected %v", paths, tt.expected)
+			}
+		})
+	}
+}
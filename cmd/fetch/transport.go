@@ -0,0 +1,57 @@
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/florinutz/git-intel/src/httpcache"
+	"github.com/florinutz/git-intel/src/ratelimit"
+	"github.com/florinutz/git-intel/src/retry"
+)
+
+// apiTransport carries the calls of every API client: through the on-disk http cache, unless it's disabled,
+// through the retries of the transient failures and through the rate limit manager shared by all the hosts
+type apiTransport struct {
+	http.RoundTripper
+	cache   *httpcache.Transport // nil when disabled or when it can't be set up
+	retrier *retry.Transport
+	limiter *ratelimit.Manager
+}
+
+// newAPITransport builds the transport, telling errOut about the retries, the rate limit pauses and about a missing cache
+func newAPITransport(noCache bool, policy *retry.Policy, errOut io.Writer) *apiTransport {
+	limiter := ratelimit.New(nil)
+	limiter.OnPause = func(host string, until time.Time, reason string) {
+		_, _ = fmt.Fprintf(errOut, "%s: %s, pausing until %s\n", host, reason, until.Local().Format(time.TimeOnly))
+	}
+	retrier := retry.NewTransport(policy, limiter)
+	retrier.OnRetry = func(req *http.Request, failure string, wait time.Duration) {
+		_, _ = fmt.Fprintf(errOut, "%s %s: %s, retrying in %s\n", req.Method, req.URL.Redacted(), failure, wait.Round(time.Millisecond))
+	}
+	t := &apiTransport{RoundTripper: retrier, retrier: retrier, limiter: limiter}
+	if noCache {
+		return t
+	}
+
+	dir, err := httpcache.DefaultDir()
+	if err == nil {
+		if t.cache, err = httpcache.New(dir, retrier); err == nil {
+			t.RoundTripper = t.cache
+			return t
+		}
+	}
+	_, _ = fmt.Fprintf(errOut, "Warning: running without the http cache: %v\n", err)
+	return t
+}
+
+// report prints the cache statistics and the remaining rate limit budgets
+func (t *apiTransport) report(out io.Writer) {
+	if t.cache != nil {
+		_, _ = fmt.Fprintf(out, "http cache: %s\n", t.cache.Stats())
+	}
+	for _, budget := range t.limiter.Budgets() {
+		_, _ = fmt.Fprintf(out, "rate limit %s\n", budget)
+	}
+}
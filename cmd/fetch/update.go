@@ -0,0 +1,258 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/florinutz/git-intel/src/progress"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+var (
+	errDirtyWorktree = errors.New("the worktree has uncommitted changes")
+	errDiverged      = errors.New("the local branch has commits that are not on the remote")
+	errDetachedHead  = errors.New("HEAD is detached")
+	// errShallowHistory tells that the history of a shallow clone stops before reaching the local branch,
+	// so whether the branch has local commits can't be told without fetching more of it
+	errShallowHistory = errors.New("the shallow history doesn't reach back to the local branch")
+)
+
+// updateRepo brings an existing clone up to date according to the target's update strategy.
+// Dirty worktrees are reported as errors and never touched. So are branches with local commits when pulling,
+// while resets move them to the remote head all the same, after keeping their commits in a backup branch.
+// Bare and mirror repositories are only fetched, the branches of bare ones following their remote counterpart.
+// Pulls and resets update the submodules as well when recurse is set.
+// Targets using the cache are fetched from their mirror in mirrors, which is refreshed first.
+// The progress of the fetch is reported to task, unless it's nil.
+func updateRepo(ctx context.Context, out io.Writer, auth *authenticator, mirrors *mirrorCache, target cloneTarget, task *progress.Task) error {
+	o := target.Options
+	strategy := o.Update.OrDefault()
+	if strategy == UpdateSkip {
+		_, _ = fmt.Fprintf(out, "%s already exists, skipping\n", target.clonePath())
+		return nil
+	}
+
+	cached, err := usesCache(mirrors, target)
+	if err != nil {
+		return err
+	}
+	var mirror string
+	if cached {
+		if mirror, err = mirrors.refresh(ctx, out, auth, target, task); err != nil {
+			return err
+		}
+	}
+
+	_, _ = fmt.Fprintf(out, "updating %s (%s)\n", target.clonePath(), strategy)
+	if task != nil && !cached {
+		task.Watch(packDir(target.clonePath(), o.Mode))
+	}
+	if o.Filter != "" {
+		env, err := auth.gitEnv(target.Auth, target.URL)
+		if err != nil {
+			return fmt.Errorf("error occurred while preparing auth for %s: %w", target.URL, err)
+		}
+		if err := updateWithGit(ctx, out, env, target, strategy, task); err != nil {
+			return err
+		}
+		return refreshSubmodules(ctx, auth, target, strategy)
+	}
+
+	authMethod, err := auth.method(target.Auth, target.URL)
+	if err != nil {
+		return fmt.Errorf("error occurred while preparing auth for %s: %w", target.URL, err)
+	}
+	repo, err := openRepo(target.clonePath())
+	if err != nil {
+		return fmt.Errorf("can't open existing repo %s: %w", target.clonePath(), err)
+	}
+
+	fetchOpts := &git.FetchOptions{
+		Auth:  authMethod,
+		Depth: o.Depth,
+		Tags:  goGitTags(o.Tags),
+	}
+	if cached {
+		fetchOpts.RemoteURL, fetchOpts.Auth, fetchOpts.Depth = mirror, nil, 0
+	}
+	if task != nil {
+		fetchOpts.Progress = task
+	}
+	err = repo.FetchContext(ctx, fetchOpts)
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("error occurred while fetching %s: %w", target.clonePath(), err)
+	}
+
+	switch {
+	case o.Mode == ModeBare:
+		return syncLocalBranches(repo, true)
+	case o.Mode == ModeMirror:
+		return nil
+	case o.AllBranches:
+		if err := syncLocalBranches(repo, false); err != nil {
+			return err
+		}
+	}
+
+	// shallow clones are fetched deeper until their history reaches back to the local branch
+	depth := max(o.Depth, 1)
+	deepen := func() error {
+		depth *= 2
+		deeper := *fetchOpts
+		deeper.Depth = depth
+		return repo.FetchContext(ctx, &deeper)
+	}
+
+	switch strategy {
+	case UpdatePull:
+		err = fastForward(repo, o.Branch, deepen)
+	case UpdateResetToRemote:
+		var backup string
+		backup, err = resetToRemote(repo, o.Branch, deepen)
+		reportBackup(out, target, backup)
+	}
+	if err != nil {
+		return err
+	}
+
+	return refreshSubmodules(ctx, auth, target, strategy)
+}
+
+// fastForward moves a local branch to the head of its origin counterpart and checks it out, like `git pull --ff-only`.
+// An empty branch means the currently checked out one. Branches with local commits are left as they are.
+func fastForward(repo *git.Repository, branch string, deepen func() error) error {
+	_, err := moveToRemote(repo, branch, false, deepen)
+	return err
+}
+
+// resetToRemote checks out a local branch and hard resets it to the head of its origin counterpart, like
+// `git checkout -B branch origin/branch`. An empty branch means the currently checked out one.
+// The local commits of a diverged branch are kept in a backup branch, whose name is returned.
+func resetToRemote(repo *git.Repository, branch string, deepen func() error) (string, error) {
+	return moveToRemote(repo, branch, true, deepen)
+}
+
+// moveToRemote moves a local branch to the head of its origin counterpart and checks it out.
+// Diverged branches are an error, unless reset is set: they're backed up first, and the backup branch is returned.
+// deepen fetches more of the history of a shallow clone, and returns git.NoErrAlreadyUpToDate when it brings nothing new.
+func moveToRemote(repo *git.Repository, branch string, reset bool, deepen func() error) (string, error) {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return "", fmt.Errorf("can't get the worktree status: %w", err)
+	}
+	if !status.IsClean() {
+		return "", errDirtyWorktree
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("can't read HEAD: %w", err)
+	}
+	if !head.Name().IsBranch() {
+		return "", errDetachedHead
+	}
+	if branch == "" {
+		branch = head.Name().Short()
+	}
+
+	remoteRef, err := repo.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, branch), true)
+	if err != nil {
+		return "", fmt.Errorf("can't find the remote branch %s/%s: %w", git.DefaultRemoteName, branch, err)
+	}
+
+	var backup string
+	localName := plumbing.NewBranchReferenceName(branch)
+	localRef, err := repo.Reference(localName, true)
+	switch {
+	case errors.Is(err, plumbing.ErrReferenceNotFound):
+		// the branch only exists on the remote, so there's nothing local to lose
+	case err != nil:
+		return "", fmt.Errorf("can't read the local branch %s: %w", branch, err)
+	default:
+		err := ensureAncestor(repo, localRef.Hash(), remoteRef.Hash())
+		for errors.Is(err, errShallowHistory) {
+			fetchErr := deepen()
+			if fetchErr != nil && !errors.Is(fetchErr, git.NoErrAlreadyUpToDate) {
+				return "", fmt.Errorf("can't deepen the history of %s: %w", branch, fetchErr)
+			}
+			err = ensureAncestor(repo, localRef.Hash(), remoteRef.Hash())
+			if errors.Is(err, errShallowHistory) && fetchErr != nil {
+				// there's no more history to fetch and the local branch still isn't found in it
+				err = errDiverged
+			}
+		}
+		switch {
+		case errors.Is(err, errDiverged) && reset:
+			backup = backupBranch(branch, localRef.Hash())
+			if err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(backup), localRef.Hash())); err != nil {
+				return "", fmt.Errorf("can't back up the local commits of %s: %w", branch, err)
+			}
+		case err != nil:
+			return "", fmt.Errorf("branch %s: %w", branch, err)
+		}
+	}
+
+	if head.Name() != localName {
+		if err := repo.Storer.SetReference(plumbing.NewHashReference(localName, remoteRef.Hash())); err != nil {
+			return backup, err
+		}
+		if err := wt.Checkout(&git.CheckoutOptions{Branch: localName}); err != nil {
+			return backup, fmt.Errorf("can't check out %s: %w", branch, err)
+		}
+	}
+
+	return backup, wt.Reset(&git.ResetOptions{Commit: remoteRef.Hash(), Mode: git.HardReset})
+}
+
+// ensureAncestor returns errDiverged if local can't be fast-forwarded to remote,
+// or errShallowHistory if the history of remote stops at a shallow boundary before local is found
+func ensureAncestor(repo *git.Repository, local, remote plumbing.Hash) error {
+	if local == remote {
+		return nil
+	}
+
+	localCommit, err := repo.CommitObject(local)
+	if err != nil {
+		return err
+	}
+	remoteCommit, err := repo.CommitObject(remote)
+	if err != nil {
+		return err
+	}
+
+	isAncestor, err := localCommit.IsAncestor(remoteCommit)
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		if shallow, _ := repo.Storer.Shallow(); len(shallow) > 0 {
+			return errShallowHistory
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("can't compare %s to %s: %w", local, remote, err)
+	}
+	if !isAncestor {
+		return errDiverged
+	}
+
+	return nil
+}
+
+// backupBranch names the branch keeping the local commits of a diverged branch before it's reset
+func backupBranch(branch string, local plumbing.Hash) string {
+	return "git-intel-backup/" + branch + "-" + local.String()[:7]
+}
+
+// reportBackup tells where the local commits of a target's branch went when the reset moved it, if it did
+func reportBackup(out io.Writer, target cloneTarget, backup string) {
+	if backup != "" {
+		_, _ = fmt.Fprintf(out, "%s had local commits, the reset kept them in the branch %s\n", target.clonePath(), backup)
+	}
+}
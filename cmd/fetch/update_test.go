@@ -0,0 +1,176 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/florinutz/git-intel/cmd/fetch/model"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// cloneLocal clones the remote into a fresh workspace and returns the clone target
+func cloneLocal(t *testing.T, remote string, update UpdateStrategy) cloneTarget {
+	t.Helper()
+
+	target := cloneTarget{Name: "repo", URL: remote, Dir: t.TempDir(), Options: &CloneOptions{Update: update}}
+	if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+		t.Fatalf("Failed to clone the remote: %v", err)
+	}
+	return target
+}
+
+func TestUpdateRepo_Pull(t *testing.T) {
+	remote := newLocalRemote(t)
+	target := cloneLocal(t, remote, UpdatePull)
+	commitFile(t, remote, "new.txt", "new\n")
+
+	if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+		t.Fatalf("cloneRepo() failed to update an existing clone: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target.clonePath(), "new.txt")); err != nil {
+		t.Errorf("the pull did not bring the new remote commit: %v", err)
+	}
+}
+
+func TestUpdateRepo_Skip(t *testing.T) {
+	remote := newLocalRemote(t)
+	target := cloneLocal(t, remote, "")
+	commitFile(t, remote, "new.txt", "new\n")
+
+	if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+		t.Fatalf("cloneRepo() failed to skip an existing clone: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target.clonePath(), "new.txt")); !os.IsNotExist(err) {
+		t.Errorf("the skip strategy updated the worktree")
+	}
+}
+
+func TestUpdateRepo_DirtyWorktree(t *testing.T) {
+	remote := newLocalRemote(t)
+	target := cloneLocal(t, remote, UpdateResetToRemote)
+	commitFile(t, remote, "new.txt", "new\n")
+
+	readme := filepath.Join(target.clonePath(), "README.md")
+	if err := os.WriteFile(readme, []byte("local change\n"), 0644); err != nil {
+		t.Fatalf("Failed to change the clone: %v", err)
+	}
+
+	err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil)
+	if !errors.Is(err, errDirtyWorktree) {
+		t.Fatalf("cloneRepo() returned %v for a dirty worktree, expected %v", err, errDirtyWorktree)
+	}
+	if content, _ := os.ReadFile(readme); string(content) != "local change\n" {
+		t.Errorf("the local change was clobbered")
+	}
+}
+
+func TestUpdateRepo_Diverged(t *testing.T) {
+	remote := newLocalRemote(t)
+	target := cloneLocal(t, remote, UpdatePull)
+	commitFile(t, remote, "remote.txt", "remote\n")
+	commitFile(t, target.clonePath(), "local.txt", "local\n")
+
+	err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil)
+	if !errors.Is(err, errDiverged) {
+		t.Fatalf("cloneRepo() returned %v for a diverged branch, expected %v", err, errDiverged)
+	}
+	if _, err := os.Stat(filepath.Join(target.clonePath(), "local.txt")); err != nil {
+		t.Errorf("the local commit was clobbered: %v", err)
+	}
+}
+
+func TestUpdateRepo_ResetDiverged(t *testing.T) {
+	remote := newLocalRemote(t)
+	target := cloneLocal(t, remote, UpdateResetToRemote)
+	commitFile(t, remote, "remote.txt", "remote\n")
+	commitFile(t, target.clonePath(), "local.txt", "local\n")
+
+	repo, err := git.PlainOpen(target.clonePath())
+	if err != nil {
+		t.Fatalf("Failed to open the clone: %v", err)
+	}
+	local, err := repo.Head()
+	if err != nil {
+		t.Fatalf("Failed to read the head of the clone: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := cloneRepo(context.Background(), &out, newAuthenticator(nil), nil, target, nil); err != nil {
+		t.Fatalf("cloneRepo() failed to reset a diverged branch: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target.clonePath(), "remote.txt")); err != nil {
+		t.Errorf("the reset did not bring the remote commit: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target.clonePath(), "local.txt")); !os.IsNotExist(err) {
+		t.Errorf("the reset left the local commit checked out")
+	}
+
+	backup := backupBranch(local.Name().Short(), local.Hash())
+	if !hasRef(t, target.clonePath(), plumbing.NewBranchReferenceName(backup)) {
+		t.Errorf("the local commit was not kept in the branch %s", backup)
+	}
+	if !strings.Contains(out.String(), backup) {
+		t.Errorf("the backup branch was not reported:\n%s", out.String())
+	}
+}
+
+func TestUpdateRepo_Shallow(t *testing.T) {
+	for _, strategy := range []UpdateStrategy{UpdatePull, UpdateResetToRemote} {
+		t.Run(string(strategy), func(t *testing.T) {
+			remote := newLocalRemote(t)
+			commitFile(t, remote, "second.txt", "second\n")
+			target := cloneTarget{Name: "repo", URL: remote, Dir: t.TempDir(), Options: &CloneOptions{Update: strategy, Depth: 1}}
+			if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+				t.Fatalf("Failed to clone the remote: %v", err)
+			}
+			// the history fetched by the update stops before the commit the clone is at
+			for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+				commitFile(t, remote, name, name+"\n")
+			}
+
+			var out bytes.Buffer
+			if err := cloneRepo(context.Background(), &out, newAuthenticator(nil), nil, target, nil); err != nil {
+				t.Fatalf("cloneRepo() failed to update a shallow clone: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(target.clonePath(), "c.txt")); err != nil {
+				t.Errorf("the update did not bring the new remote commits: %v", err)
+			}
+			if strings.Contains(out.String(), "backup") {
+				t.Errorf("the update backed up a branch without local commits:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestUpdateRepo_PullConfiguredBranch(t *testing.T) {
+	remote := newBranchedRemote(t)
+	target := cloneLocal(t, remote, UpdatePull)
+	target.Options.Branch = "feature"
+
+	repo, err := git.PlainOpen(remote)
+	if err != nil {
+		t.Fatalf("Failed to open the remote: %v", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get the remote worktree: %v", err)
+	}
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature")}); err != nil {
+		t.Fatalf("Failed to check out the feature branch: %v", err)
+	}
+	commitFile(t, remote, "more.txt", "more\n")
+
+	if err := cloneRepo(context.Background(), io.Discard, newAuthenticator(nil), nil, target, nil); err != nil {
+		t.Fatalf("cloneRepo() failed to pull the configured branch: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target.clonePath(), "more.txt")); err != nil {
+		t.Errorf("the pull did not bring the new commit of the configured branch: %v", err)
+	}
+}
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/florinutz/git-intel/cmd/fetch"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
+	// the first Ctrl-C cancels the context the commands run with, so they can wind down, the second one kills
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	rootCmd := buildRootCommand()
-	if err := rootCmd.Execute(); err != nil {
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		_, _ = fmt.Fprintf(rootCmd.ErrOrStderr(), "%v\n", err)
 	}
 }
@@ -40,6 +52,7 @@ func buildRootCommand() *cobra.Command {
 	cmd.AddCommand(
 		fetch.BuildFetchCmd(),
 		fetch.BuildConfigGenCmd(),
+		fetch.BuildExplainCmd(),
 	)
 
 	cmd.PersistentFlags().StringVarP(&opts.cfgFile, "config", "c", "", "config file")
@@ -0,0 +1,185 @@
+// Package httpcache keeps API responses on disk and revalidates them with conditional requests,
+// so unchanged listings cost a 304 instead of a full download. GitHub doesn't count 304s against the rate limit.
+package httpcache
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// Stats counts what the cache did
+type Stats struct {
+	Requests    int64 // the cacheable requests, GETs
+	Revalidated int64 // the requests answered from the cache after a 304
+	Stored      int64 // the responses written to the cache
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d requests, %d answered from the cache, %d responses cached", s.Requests, s.Revalidated, s.Stored)
+}
+
+// Transport is an http.RoundTripper that stores the GET responses carrying an ETag or a Last-Modified header
+// in a directory, and revalidates them with If-None-Match and If-Modified-Since on the next requests.
+// The cache is best effort: entries that can't be read or written are skipped. It is safe for concurrent use.
+type Transport struct {
+	Dir  string
+	Base http.RoundTripper // defaults to http.DefaultTransport
+
+	requests    atomic.Int64
+	revalidated atomic.Int64
+	stored      atomic.Int64
+}
+
+// New returns a transport caching into dir, which is created if needed
+func New(dir string, base http.RoundTripper) (*Transport, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("can't create the http cache directory '%s': %w", dir, err)
+	}
+	return &Transport{Dir: dir, Base: base}, nil
+}
+
+// DefaultDir is the cache directory of git-intel inside the user's cache directory
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "git-intel", "http"), nil
+}
+
+// Stats returns what the cache did so far
+func (t *Transport) Stats() Stats {
+	return Stats{Requests: t.requests.Load(), Revalidated: t.revalidated.Load(), Stored: t.stored.Load()}
+}
+
+// notModifiedSkipped are the headers of a 304 that describe its empty body, they don't replace the cached ones
+var notModifiedSkipped = map[string]bool{
+	"Content-Length":    true,
+	"Content-Type":      true,
+	"Content-Encoding":  true,
+	"Transfer-Encoding": true,
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return t.base().RoundTrip(req)
+	}
+	t.requests.Add(1)
+
+	path := t.path(req)
+	cached := t.load(path, req)
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if modified := cached.Header.Get("Last-Modified"); modified != "" {
+			req.Header.Set("If-Modified-Since", modified)
+		}
+	}
+
+	resp, err := t.base().RoundTrip(req)
+	if err != nil {
+		if cached != nil {
+			cached.Body.Close()
+		}
+		return nil, err
+	}
+
+	if cached != nil {
+		if resp.StatusCode == http.StatusNotModified {
+			resp.Body.Close()
+			t.revalidated.Add(1)
+			// the fresh headers, like the rate limit ones, replace the stored ones
+			for k, v := range resp.Header {
+				if !notModifiedSkipped[k] {
+					cached.Header[k] = v
+				}
+			}
+			return cached, nil
+		}
+		cached.Body.Close()
+	}
+
+	if resp.StatusCode == http.StatusOK && (resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "") {
+		if t.store(path, resp) == nil {
+			t.stored.Add(1)
+		}
+	}
+	return resp, nil
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// path returns the cache file of a request. The credentials are part of the key,
+// so a token never gets responses cached for another one.
+func (t *Transport) path(req *http.Request) string {
+	h := sha256.New()
+	for _, part := range []string{req.URL.String(), req.Header.Get("Accept"), req.Header.Get("Authorization"), req.Header.Get("PRIVATE-TOKEN")} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return filepath.Join(t.Dir, hex.EncodeToString(h.Sum(nil)))
+}
+
+// load reads a cached response, or returns nil if there is none
+func (t *Transport) load(path string, req *http.Request) *http.Response {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(f), req)
+	if err != nil {
+		f.Close()
+		return nil
+	}
+	resp.Body = &fileBody{ReadCloser: resp.Body, file: f}
+	return resp
+}
+
+// store writes a response to the cache. Its body is read and replaced by an in-memory copy.
+func (t *Transport) store(path string, resp *http.Response) error {
+	dump, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return err
+	}
+
+	// written aside and renamed, so concurrent readers never see half an entry
+	tmp, err := os.CreateTemp(t.Dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(dump); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// fileBody is the body of a cached response, closing it closes the cache file
+type fileBody struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (b *fileBody) Close() error {
+	b.ReadCloser.Close()
+	return b.file.Close()
+}
@@ -0,0 +1,121 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newETagServer serves a chunked body with an ETag, answering 304 to the requests that already have it
+func newETagServer(t *testing.T, body string, hits *int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*hits++
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		// flushed before the body, so the response is chunked like the large API listings
+		w.(http.Flusher).Flush()
+		_, _ = io.WriteString(w, body)
+	}))
+}
+
+func get(t *testing.T, client *http.Client, url, token string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("Failed to build the request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send the request: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read the body: %v", err)
+	}
+	return resp, string(body)
+}
+
+func TestTransport_Revalidates(t *testing.T) {
+	var hits int
+	server := newETagServer(t, `[{"name": "api"}]`, &hits)
+	defer server.Close()
+
+	cache, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("New() returned an error: %v", err)
+	}
+	client := &http.Client{Transport: cache}
+
+	for i := 0; i < 3; i++ {
+		resp, body := get(t, client, server.URL+"/orgs/acme/repos", "token")
+		if resp.StatusCode != http.StatusOK || body != `[{"name": "api"}]` {
+			t.Errorf("request %d returned %d %q", i, resp.StatusCode, body)
+		}
+		if resp.Header.Get("Content-Type") != "application/json" || resp.Header.Get("X-RateLimit-Remaining") != "4999" {
+			t.Errorf("request %d returned the headers %v", i, resp.Header)
+		}
+	}
+
+	if hits != 3 {
+		t.Errorf("the server was hit %d times, expected every request to be revalidated", hits)
+	}
+	if stats := cache.Stats(); stats != (Stats{Requests: 3, Revalidated: 2, Stored: 1}) {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestTransport_KeysByCredentials(t *testing.T) {
+	var hits int
+	server := newETagServer(t, "private", &hits)
+	defer server.Close()
+
+	cache, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("New() returned an error: %v", err)
+	}
+	client := &http.Client{Transport: cache}
+
+	get(t, client, server.URL, "alice")
+	get(t, client, server.URL, "bob")
+	get(t, client, server.URL, "")
+
+	if stats := cache.Stats(); stats.Revalidated != 0 || stats.Stored != 3 {
+		t.Errorf("responses were shared between credentials: %+v", stats)
+	}
+}
+
+func TestTransport_SkipsUncacheable(t *testing.T) {
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		_, _ = io.WriteString(w, "no validators")
+	}))
+	defer server.Close()
+
+	cache, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("New() returned an error: %v", err)
+	}
+	client := &http.Client{Transport: cache}
+
+	get(t, client, server.URL, "")
+	get(t, client, server.URL, "")
+	if _, err := client.Post(server.URL, "application/json", nil); err != nil {
+		t.Fatalf("Failed to send the POST request: %v", err)
+	}
+
+	if stats := cache.Stats(); stats != (Stats{Requests: 2}) || hits != 3 {
+		t.Errorf("Stats() = %+v after %d hits, expected nothing cached", stats, hits)
+	}
+}
@@ -0,0 +1,19 @@
+// Package httpreq holds the request helpers shared by the http transports.
+package httpreq
+
+import "net/http"
+
+// Rewind returns a copy of req that can be sent again, with a fresh body.
+// Requests whose body can't be read again are copied with the body they have.
+func Rewind(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.Body == nil || req.GetBody == nil {
+		return clone, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	clone.Body = body
+	return clone, nil
+}
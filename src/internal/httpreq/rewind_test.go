@@ -0,0 +1,29 @@
+package httpreq
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRewind(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://api.github.com/graphql", strings.NewReader(`{"query":"{}"}`))
+	if err != nil {
+		t.Fatalf("Failed to build the request: %v", err)
+	}
+	if _, err := io.ReadAll(req.Body); err != nil {
+		t.Fatalf("Failed to read the body: %v", err)
+	}
+
+	again, err := Rewind(req)
+	if err != nil {
+		t.Fatalf("Rewind() returned an error: %v", err)
+	}
+	if body, _ := io.ReadAll(again.Body); string(body) != `{"query":"{}"}` {
+		t.Errorf("Rewind() gave the body %q, expected the original one", body)
+	}
+	if again == req || again.URL.String() != req.URL.String() {
+		t.Errorf("Rewind() did not return a copy of the request")
+	}
+}
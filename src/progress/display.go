@@ -0,0 +1,352 @@
+// Package progress shows what concurrent clones are doing while they run.
+package progress
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// refreshInterval is how often the live display is redrawn
+const refreshInterval = 200 * time.Millisecond
+
+// barWidth is the number of cells of the overall progress bar
+const barWidth = 30
+
+// Display renders the progress of a batch of clones. On a terminal it keeps a live region at the bottom
+// with one line per active clone, showing the last message of the git server, the bytes received and the speed,
+// and an overall bar below them. Anything else written to the display is printed above the live region.
+// When the output is not a terminal it falls back to plain logging: one line per finished git stage
+// and one line per finished clone. It is safe for concurrent use.
+type Display struct {
+	out   io.Writer
+	live  bool
+	width int // of the terminal, 0 when unknown
+	total int
+
+	mu       sync.Mutex
+	tasks    []*Task // the active ones, in the order they started
+	finished int
+	failed   int
+	drawn    int // lines of the live region currently on screen
+	closed   bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns a display for total clones, live if stdout and out are both terminals: the output piped
+// elsewhere, as in `git-intel fetch | tee log`, gets plain log lines even when out is still the terminal.
+// A total of 0 leaves it to SetTotal, nothing but the log lines is shown until then.
+func New(out io.Writer, total int) *Display {
+	d := &Display{out: out, total: total}
+	f, ok := out.(*os.File)
+	if ok && term.IsTerminal(int(os.Stdout.Fd())) && term.IsTerminal(int(f.Fd())) && os.Getenv("TERM") != "dumb" {
+		d.live = true
+		if width, _, err := term.GetSize(int(f.Fd())); err == nil {
+			d.width = width
+		}
+	}
+	if d.live {
+		d.stop, d.done = make(chan struct{}), make(chan struct{})
+		go d.refresh()
+	}
+	return d
+}
+
+// Write prints log lines, above the live region when there is one
+func (d *Display) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.clear()
+	n, err := d.out.Write(p)
+	d.draw()
+	return n, err
+}
+
+// SetTotal sets how many clones the overall progress bar counts
+func (d *Display) SetTotal(total int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.total = total
+	d.clear()
+	d.draw()
+}
+
+// Pause removes the live region and holds every write to the display, until resume is called.
+// It clears the terminal for an interactive prompt, which must not write to the display.
+func (d *Display) Pause() (resume func()) {
+	d.mu.Lock()
+	d.clear()
+	return func() {
+		d.draw()
+		d.mu.Unlock()
+	}
+}
+
+// Start adds a clone to the display
+func (d *Display) Start(name string) *Task {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t := &Task{d: d, name: name, started: time.Now()}
+	d.tasks = append(d.tasks, t)
+	d.clear()
+	d.draw()
+	return t
+}
+
+// Close stops redrawing and removes the live region, leaving the log lines on screen.
+// Log lines written afterwards are still printed. Closing it again does nothing.
+func (d *Display) Close() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	d.mu.Unlock()
+
+	if d.live {
+		close(d.stop)
+		<-d.done
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.clear()
+}
+
+func (d *Display) refresh() {
+	defer close(d.done)
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case now := <-ticker.C:
+			d.mu.Lock()
+			for _, t := range d.tasks {
+				t.measure(now)
+			}
+			d.clear()
+			d.draw()
+			d.mu.Unlock()
+		}
+	}
+}
+
+// clear erases the live region, the cursor ending up where it started. Called with mu held.
+func (d *Display) clear() {
+	if !d.live || d.drawn == 0 {
+		return
+	}
+	_, _ = io.WriteString(d.out, strings.Repeat("\x1b[1A\x1b[2K", d.drawn))
+	d.drawn = 0
+}
+
+// draw prints the live region below the cursor, once it was cleared. Called with mu held.
+func (d *Display) draw() {
+	if !d.live || d.closed || (d.total == 0 && len(d.tasks) == 0) {
+		return
+	}
+
+	var buf bytes.Buffer
+	nameWidth := 0
+	for _, t := range d.tasks {
+		nameWidth = max(nameWidth, len(t.name))
+	}
+	for _, t := range d.tasks {
+		buf.WriteString(d.fit(t.line(nameWidth)) + "\n")
+	}
+	buf.WriteString(d.fit(d.bar()) + "\n")
+
+	_, _ = d.out.Write(buf.Bytes())
+	d.drawn = len(d.tasks) + 1
+}
+
+// bar renders the overall progress
+func (d *Display) bar() string {
+	filled := 0
+	if d.total > 0 {
+		filled = d.finished * barWidth / d.total
+	}
+	line := fmt.Sprintf("[%s%s] %d/%d done", strings.Repeat("=", filled), strings.Repeat(" ", barWidth-filled), d.finished, d.total)
+	if d.failed > 0 {
+		line += fmt.Sprintf(", %d failed", d.failed)
+	}
+	if len(d.tasks) > 0 {
+		line += fmt.Sprintf(", %d in progress", len(d.tasks))
+	}
+	return line
+}
+
+// fit truncates a line to the width of the terminal, so that it doesn't wrap and break the redraws
+func (d *Display) fit(line string) string {
+	if d.width > 0 && len(line) >= d.width {
+		return line[:d.width-1]
+	}
+	return line
+}
+
+// finish removes a task from the live region and logs its outcome. Called with mu held.
+func (d *Display) finish(t *Task, err error) {
+	for i, active := range d.tasks {
+		if active == t {
+			d.tasks = append(d.tasks[:i], d.tasks[i+1:]...)
+			break
+		}
+	}
+	d.finished++
+	if err != nil {
+		d.failed++
+	}
+
+	d.clear()
+	elapsed := time.Since(t.started).Round(100 * time.Millisecond)
+	switch {
+	case err != nil:
+		_, _ = fmt.Fprintf(d.out, "[%d/%d] %s: failed after %s\n", d.finished, d.total, t.name, elapsed)
+	case t.bytes > 0:
+		_, _ = fmt.Fprintf(d.out, "[%d/%d] %s: done in %s, %s received\n", d.finished, d.total, t.name, elapsed, formatBytes(t.bytes))
+	default:
+		_, _ = fmt.Fprintf(d.out, "[%d/%d] %s: done in %s\n", d.finished, d.total, t.name, elapsed)
+	}
+	d.draw()
+}
+
+// Task is a single clone or fetch on the display. It is the io.Writer go-git's Progress option expects,
+// receiving the progress messages of the git server.
+type Task struct {
+	d       *Display
+	name    string
+	started time.Time
+
+	// guarded by d.mu
+	status   string // last progress message
+	partial  []byte // progress message not terminated yet
+	dir      string // directory the received objects are written to
+	baseline int64  // size of dir when it started being watched
+	bytes    int64
+	lastAt   time.Time
+	speed    float64 // bytes per second
+}
+
+// Write receives the progress messages of the git server. Messages are terminated by "\r" while they are
+// updated in place and by "\n" when the stage they report is over.
+func (t *Task) Write(p []byte) (int, error) {
+	t.d.mu.Lock()
+	defer t.d.mu.Unlock()
+
+	t.partial = append(t.partial, p...)
+	for {
+		end := bytes.IndexAny(t.partial, "\r\n")
+		if end < 0 {
+			break
+		}
+		message := strings.TrimSpace(string(t.partial[:end]))
+		final := t.partial[end] == '\n'
+		t.partial = t.partial[end+1:]
+
+		if message == "" {
+			continue
+		}
+		t.status = message
+		if final && !t.d.live {
+			_, _ = fmt.Fprintf(t.d.out, "%s: %s\n", t.name, message)
+		}
+	}
+	return len(p), nil
+}
+
+// Watch measures the bytes received by the size dir grows by. It replaces the directory watched before,
+// an empty dir stops watching and keeps the bytes measured so far, e.g. before the directory is moved away.
+func (t *Task) Watch(dir string) {
+	t.d.mu.Lock()
+	defer t.d.mu.Unlock()
+
+	t.measure(time.Now())
+	if dir == "" {
+		t.dir = ""
+		return
+	}
+	t.dir = dir
+	t.baseline = dirSize(dir)
+	t.bytes, t.speed, t.lastAt = 0, 0, time.Now()
+}
+
+// Done removes the task from the display and logs its outcome
+func (t *Task) Done(err error) {
+	t.d.mu.Lock()
+	defer t.d.mu.Unlock()
+
+	t.measure(time.Now())
+	t.d.finish(t, err)
+}
+
+// measure updates the bytes received and the speed. Called with d.mu held.
+func (t *Task) measure(now time.Time) {
+	if t.dir == "" {
+		return
+	}
+	received := max(dirSize(t.dir)-t.baseline, 0)
+	if elapsed := now.Sub(t.lastAt).Seconds(); elapsed > 0 {
+		t.speed = float64(received-t.bytes) / elapsed
+	}
+	t.bytes, t.lastAt = received, now
+}
+
+// line renders the task for the live region, its name padded to nameWidth
+func (t *Task) line(nameWidth int) string {
+	status := t.status
+	if status == "" {
+		status = "waiting for the server"
+	}
+	line := fmt.Sprintf("  %-*s  %s", nameWidth, t.name, status)
+	if t.bytes > 0 {
+		line += fmt.Sprintf("  %s  %s/s", formatBytes(t.bytes), formatBytes(int64(max(t.speed, 0))))
+	}
+	return line
+}
+
+// dirSize returns the size of the files under dir, 0 if it doesn't exist (yet)
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// formatBytes renders a byte count in binary units, e.g. 1.5 MiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,141 @@
+package progress
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDisplay_PlainLogging(t *testing.T) {
+	var out strings.Builder
+	d := New(&out, 2)
+
+	api := d.Start("api")
+	_, _ = api.Write([]byte("Counting objects:  50% (10/20)\rCounting objects: 100% (20/20)"))
+	_, _ = api.Write([]byte(", done.\nCompressing objects:  10% (1/10)\r"))
+	_, _ = d.Write([]byte("cloning web\n"))
+	d.Start("web").Done(errors.New("connection reset"))
+	api.Done(nil)
+	d.Close()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("the display logged %q, expected a line per finished stage, log line and clone", lines)
+	}
+	if lines[0] != "api: Counting objects: 100% (20/20), done." || lines[1] != "cloning web" {
+		t.Errorf("unexpected log lines: %q", lines[:2])
+	}
+	if !strings.HasPrefix(lines[2], "[1/2] web: failed after") || !strings.HasPrefix(lines[3], "[2/2] api: done in") {
+		t.Errorf("unexpected outcome lines: %q", lines[2:])
+	}
+	if strings.Contains(out.String(), "\x1b[") {
+		t.Errorf("the plain display wrote escape codes: %q", out.String())
+	}
+}
+
+func TestDisplay_LiveRegion(t *testing.T) {
+	var out strings.Builder
+	d := &Display{out: &out, live: true, total: 3}
+
+	api := d.Start("api")
+	d.Start("web-frontend")
+	_, _ = api.Write([]byte("Compressing objects:  45% (9/20)\r"))
+	out.Reset()
+	d.clear()
+	d.draw()
+
+	screen := out.String()
+	if !strings.HasPrefix(screen, "\x1b[1A\x1b[2K\x1b[1A\x1b[2K\x1b[1A\x1b[2K") {
+		t.Errorf("the redraw did not erase the 3 lines of the live region: %q", screen)
+	}
+	for _, expected := range []string{
+		"  api           Compressing objects:  45% (9/20)\n",
+		"  web-frontend  waiting for the server\n",
+		"[" + strings.Repeat(" ", barWidth) + "] 0/3 done, 2 in progress\n",
+	} {
+		if !strings.Contains(screen, expected) {
+			t.Errorf("the live region %q does not contain %q", screen, expected)
+		}
+	}
+
+	api.Done(nil)
+	if d.drawn != 2 || !strings.Contains(out.String(), "[1/3] api: done in") {
+		t.Errorf("the finished clone was not moved out of the live region: %q", out.String())
+	}
+}
+
+func TestDisplay_SetTotal(t *testing.T) {
+	var out strings.Builder
+	d := &Display{out: &out, live: true}
+
+	_, _ = d.Write([]byte("resolving\n"))
+	if out.String() != "resolving\n" {
+		t.Errorf("the display drew a live region before knowing the total: %q", out.String())
+	}
+
+	d.SetTotal(2)
+	if !strings.HasSuffix(out.String(), "] 0/2 done\n") {
+		t.Errorf("the display did not draw the bar for the total: %q", out.String())
+	}
+}
+
+func TestDisplay_Pause(t *testing.T) {
+	var out strings.Builder
+	d := &Display{out: &out, live: true, total: 1}
+	d.Start("api")
+
+	resume := d.Pause()
+	if d.drawn != 0 {
+		t.Errorf("the live region was left on screen during the pause")
+	}
+	written := make(chan struct{})
+	go func() {
+		_, _ = d.Write([]byte("retrying\n"))
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Errorf("a log line was written during the pause")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	resume()
+	<-written
+	if !strings.Contains(out.String(), "retrying\n") || d.drawn != 2 {
+		t.Errorf("the display did not resume: %q", out.String())
+	}
+}
+
+func TestTask_Watch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "existing.pack"), make([]byte, 4096), 0644); err != nil {
+		t.Fatalf("Failed to write the existing pack: %v", err)
+	}
+
+	var out strings.Builder
+	task := New(&out, 1).Start("api")
+	task.Watch(dir)
+	if err := os.WriteFile(filepath.Join(dir, "tmp_pack_1"), make([]byte, 3*1024*1024), 0644); err != nil {
+		t.Fatalf("Failed to write the received pack: %v", err)
+	}
+	task.Watch("")
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("Failed to remove the directory: %v", err)
+	}
+	task.Done(nil)
+
+	if !strings.Contains(out.String(), "3.0 MiB received") {
+		t.Errorf("the display logged %q, expected the size the directory grew by", out.String())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, expected := range map[int64]string{512: "512 B", 1536: "1.5 KiB", 5 * 1024 * 1024: "5.0 MiB", 3 << 30: "3.0 GiB"} {
+		if got := formatBytes(n); got != expected {
+			t.Errorf("formatBytes(%d) = %q, expected %q", n, got, expected)
+		}
+	}
+}
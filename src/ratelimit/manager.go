@@ -0,0 +1,274 @@
+// Package ratelimit keeps the API calls of all the clients within the rate limits of their hosts.
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/florinutz/git-intel/src/internal/httpreq"
+)
+
+// DefaultMaxWait is the longest pause waited out when MaxWait isn't set.
+// GitHub's primary limits reset every hour.
+const DefaultMaxWait = time.Hour
+
+// maxRetries is how many times a rate limited request is sent again after waiting
+const maxRetries = 3
+
+// secondaryBackoff is the pause after a secondary rate limit that doesn't say how long to wait,
+// GitHub asks for at least a minute
+const secondaryBackoff = time.Minute
+
+// Budget is what is left of the rate limit of a host's resource, as its last response told
+type Budget struct {
+	Host      string
+	Resource  string // core, search, graphql...
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+func (b Budget) String() string {
+	return fmt.Sprintf("%s %s: %d/%d left, resets at %s", b.Host, b.Resource, b.Remaining, b.Limit, b.Reset.Local().Format(time.TimeOnly))
+}
+
+// Manager is an http.RoundTripper shared by all the API clients. It reads the X-RateLimit-* headers of every
+// response, pauses the requests to a host's resource once its limit is used up and resumes them when it resets.
+// Rate limited responses are waited out, honoring Retry-After, and sent again.
+// Pauses longer than MaxWait are not waited out: the rate limited response is returned as is.
+// It is safe for concurrent use.
+type Manager struct {
+	Base    http.RoundTripper // defaults to http.DefaultTransport
+	MaxWait time.Duration     // defaults to DefaultMaxWait
+	// OnPause is called when the requests to a host are paused, e.g. to tell the user why nothing happens
+	OnPause func(host string, until time.Time, reason string)
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+
+	mu          sync.Mutex
+	budgets     map[string]Budget    // keyed by host and resource
+	pausedUntil map[string]time.Time // keyed by host and resource, an empty resource pausing the whole host
+}
+
+func New(base http.RoundTripper) *Manager {
+	return &Manager{
+		Base:        base,
+		now:         time.Now,
+		sleep:       sleepContext,
+		budgets:     make(map[string]Budget),
+		pausedUntil: make(map[string]time.Time),
+	}
+}
+
+// Budgets returns the last known budget of every host and resource, sorted
+func (m *Manager) Budgets() []Budget {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	budgets := make([]Budget, 0, len(m.budgets))
+	for _, b := range m.budgets {
+		budgets = append(budgets, b)
+	}
+	slices.SortFunc(budgets, func(a, b Budget) int {
+		return strings.Compare(a.Host+"/"+a.Resource, b.Host+"/"+b.Resource)
+	})
+	return budgets
+}
+
+func (m *Manager) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	host, resource := req.URL.Host, resourceOf(req)
+
+	for attempt := 0; ; attempt++ {
+		if err := m.waitPause(ctx, host, resource); err != nil {
+			return nil, err
+		}
+
+		send := req
+		if attempt > 0 {
+			var err error
+			if send, err = httpreq.Rewind(req); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := m.base().RoundTrip(send)
+		if err != nil {
+			return nil, err
+		}
+
+		budget, known := m.update(host, resource, resp)
+		until, reason, pauseHost := m.limited(resp)
+		if until.IsZero() {
+			if known && budget.Remaining == 0 && budget.Reset.After(m.now()) && m.acceptable(budget.Reset) {
+				// the last call of the window went through. Its response is held until the reset,
+				// so the clients don't give up on the next calls before even sending them.
+				m.pause(host, budget.Resource, budget.Reset, "rate limit used up")
+				if err := m.waitPause(ctx, host, resource); err != nil {
+					resp.Body.Close()
+					return nil, err
+				}
+			}
+			return resp, nil
+		}
+
+		if attempt >= maxRetries || !m.acceptable(until) || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		switch {
+		case pauseHost:
+			m.pause(host, "", until, reason)
+		case known:
+			m.pause(host, budget.Resource, until, reason)
+		default:
+			m.pause(host, resource, until, reason)
+		}
+	}
+}
+
+func (m *Manager) base() http.RoundTripper {
+	if m.Base == nil {
+		return http.DefaultTransport
+	}
+	return m.Base
+}
+
+// acceptable tells if a pause until the given time is short enough to be waited out
+func (m *Manager) acceptable(until time.Time) bool {
+	maxWait := m.MaxWait
+	if maxWait == 0 {
+		maxWait = DefaultMaxWait
+	}
+	return until.Sub(m.now()) <= maxWait
+}
+
+// pause holds the requests to a host's resource, or to the whole host for an empty resource, until the given time
+func (m *Manager) pause(host, resource string, until time.Time, reason string) {
+	m.mu.Lock()
+	key := host + "/" + resource
+	extended := until.After(m.pausedUntil[key])
+	if extended {
+		m.pausedUntil[key] = until
+	}
+	m.mu.Unlock()
+
+	if extended && m.OnPause != nil {
+		m.OnPause(host, until, reason)
+	}
+}
+
+// waitPause waits until neither the host nor its resource are paused
+func (m *Manager) waitPause(ctx context.Context, host, resource string) error {
+	for {
+		m.mu.Lock()
+		until := m.pausedUntil[host+"/"]
+		if resourceUntil := m.pausedUntil[host+"/"+resource]; resourceUntil.After(until) {
+			until = resourceUntil
+		}
+		m.mu.Unlock()
+
+		wait := until.Sub(m.now())
+		if wait <= 0 {
+			return nil
+		}
+		if err := m.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// update records the budget a response reports, in the X-RateLimit-* headers of GitHub and Gitea
+// or in the RateLimit-* ones of GitLab
+func (m *Manager) update(host, resource string, resp *http.Response) (Budget, bool) {
+	remaining, ok := rateLimitHeader(resp.Header, "Remaining")
+	if !ok {
+		return Budget{}, false
+	}
+	b := Budget{Host: host, Resource: resource, Remaining: remaining}
+	b.Limit, _ = rateLimitHeader(resp.Header, "Limit")
+	if reset, ok := rateLimitHeader(resp.Header, "Reset"); ok {
+		b.Reset = time.Unix(int64(reset), 0)
+	}
+	if r := resp.Header.Get("X-RateLimit-Resource"); r != "" {
+		b.Resource = r
+	}
+
+	m.mu.Lock()
+	m.budgets[host+"/"+b.Resource] = b
+	m.mu.Unlock()
+	return b, true
+}
+
+// limited tells if a response was rate limited and until when. Secondary limits pause the whole host.
+func (m *Manager) limited(resp *http.Response) (until time.Time, reason string, pauseHost bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return time.Time{}, "", false
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return m.now().Add(time.Duration(seconds) * time.Second), "secondary rate limit", true
+	}
+	if remaining, ok := rateLimitHeader(resp.Header, "Remaining"); ok && remaining == 0 {
+		if reset, ok := rateLimitHeader(resp.Header, "Reset"); ok {
+			return time.Unix(int64(reset), 0), "rate limit exceeded", false
+		}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || mentionsSecondaryLimit(resp) {
+		return m.now().Add(secondaryBackoff), "secondary rate limit", true
+	}
+	return time.Time{}, "", false
+}
+
+// mentionsSecondaryLimit tells if the body of a 403 is about a secondary rate limit. The body stays readable.
+func mentionsSecondaryLimit(resp *http.Response) bool {
+	head, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+	return bytes.Contains(bytes.ToLower(head), []byte("secondary rate limit"))
+}
+
+// rateLimitHeader reads a numeric rate limit header, with or without the X- prefix
+func rateLimitHeader(h http.Header, name string) (int, bool) {
+	for _, key := range []string{"X-RateLimit-" + name, "RateLimit-" + name} {
+		if v := h.Get(key); v != "" {
+			n, err := strconv.Atoi(v)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
+
+// resourceOf guesses the rate limit resource a request counts against, before its response names it
+func resourceOf(req *http.Request) string {
+	switch {
+	case strings.Contains(req.URL.Path, "/search/"):
+		return "search"
+	case strings.HasSuffix(req.URL.Path, "/graphql"):
+		return "graphql"
+	}
+	return "core"
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
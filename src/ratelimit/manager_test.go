@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock stands in for time in the manager, sleeping only moves it forward
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(_ context.Context, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func newTestManager(t *testing.T) (*Manager, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	m := New(nil)
+	m.now = clock.Now
+	m.sleep = clock.Sleep
+	return m, clock
+}
+
+// rateHeaders writes the rate limit headers GitHub sends along with every response
+func rateHeaders(w http.ResponseWriter, resource string, remaining int, reset time.Time) {
+	w.Header().Set("X-RateLimit-Limit", "5000")
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	w.Header().Set("X-RateLimit-Resource", resource)
+}
+
+func send(t *testing.T, m *Manager, method, url, body string) *http.Response {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatalf("Failed to build the request: %v", err)
+	}
+	resp, err := m.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() returned an error: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestManager_WaitsForThePrimaryReset(t *testing.T) {
+	m, clock := newTestManager(t)
+	reset := clock.Now().Add(10 * time.Minute)
+
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if clock.Now().Before(reset) {
+			rateHeaders(w, "core", 0, reset)
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, `{"message": "API rate limit exceeded"}`)
+			return
+		}
+		rateHeaders(w, "core", 4999, reset.Add(time.Hour))
+		_, _ = io.WriteString(w, "[]")
+	}))
+	defer server.Close()
+
+	var paused []string
+	m.OnPause = func(host string, until time.Time, reason string) { paused = append(paused, reason) }
+
+	resp := send(t, m, http.MethodGet, server.URL+"/orgs/acme/repos", "")
+	if resp.StatusCode != http.StatusOK || calls != 2 {
+		t.Errorf("RoundTrip() returned %d after %d calls, expected a single retry after the reset", resp.StatusCode, calls)
+	}
+	if len(clock.sleeps) != 1 || clock.sleeps[0] != 10*time.Minute {
+		t.Errorf("the manager slept %v, expected until the reset", clock.sleeps)
+	}
+	if len(paused) != 1 || paused[0] != "rate limit exceeded" {
+		t.Errorf("OnPause() was called for %v", paused)
+	}
+
+	budgets := m.Budgets()
+	if len(budgets) != 1 || budgets[0].Remaining != 4999 || budgets[0].Limit != 5000 || budgets[0].Resource != "core" {
+		t.Errorf("Budgets() = %v", budgets)
+	}
+}
+
+func TestManager_HonorsRetryAfter(t *testing.T) {
+	m, clock := newTestManager(t)
+
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, `{"message": "You have exceeded a secondary rate limit"}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"data": {}}`)
+	}))
+	defer server.Close()
+
+	resp := send(t, m, http.MethodPost, server.URL+"/graphql", `{"query": "{ viewer { login } }"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("RoundTrip() returned %d, expected the retry to go through", resp.StatusCode)
+	}
+	if len(clock.sleeps) != 1 || clock.sleeps[0] != 30*time.Second {
+		t.Errorf("the manager slept %v, expected the Retry-After", clock.sleeps)
+	}
+	if len(bodies) != 2 || bodies[1] != bodies[0] {
+		t.Errorf("the retry was sent with the body %q", bodies)
+	}
+}
+
+func TestManager_HoldsTheLastCallOfTheWindow(t *testing.T) {
+	m, clock := newTestManager(t)
+	reset := clock.Now().Add(time.Minute)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rateHeaders(w, "search", 0, reset)
+		_, _ = io.WriteString(w, `{"items": []}`)
+	}))
+	defer server.Close()
+
+	resp := send(t, m, http.MethodGet, server.URL+"/search/repositories?q=org:acme", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("RoundTrip() returned %d", resp.StatusCode)
+	}
+	if !clock.Now().Equal(reset) {
+		t.Errorf("the response was returned at %v, expected it to be held until the reset at %v", clock.Now(), reset)
+	}
+}
+
+func TestManager_GivesUpOnLongPauses(t *testing.T) {
+	m, clock := newTestManager(t)
+	m.MaxWait = time.Minute
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rateHeaders(w, "core", 0, clock.Now().Add(time.Hour))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	resp := send(t, m, http.MethodGet, server.URL+"/orgs/acme/repos", "")
+	if resp.StatusCode != http.StatusForbidden || len(clock.sleeps) != 0 {
+		t.Errorf("RoundTrip() returned %d after sleeping %v, expected the rate limited response right away", resp.StatusCode, clock.sleeps)
+	}
+}
@@ -0,0 +1,51 @@
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// APIError is a non successful response of a provider's REST API
+type APIError struct {
+	StatusCode int
+	URL        string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.URL, e.StatusCode, e.Body)
+}
+
+// maxErrorBody caps how much of an error response ends up in an APIError
+const maxErrorBody = 512
+
+// getJSON sends a GET request with the given headers and decodes the JSON response into out
+func getJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, out any) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return resp, &APIError{StatusCode: resp.StatusCode, URL: url, Body: string(body)}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp, fmt.Errorf("can't decode the response of %s: %w", url, err)
+	}
+	return resp, nil
+}
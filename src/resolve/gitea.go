@@ -0,0 +1,175 @@
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// giteaPageSize is the page size asked for, Gitea caps it with its MAX_RESPONSE_ITEMS setting
+const giteaPageSize = 50
+
+// GiteaProvider lists repositories through the Gitea REST API, which Forgejo serves as well
+type GiteaProvider struct {
+	Host    string
+	SSHHost string // host of the ssh clone urls, when it differs from Host
+	BaseURL string // e.g. https://codeberg.org/api/v1
+	Token   string
+	Client  *http.Client
+}
+
+func NewGiteaProvider(host, baseURL, token string, client *http.Client) *GiteaProvider {
+	return &GiteaProvider{Host: host, BaseURL: strings.TrimRight(baseURL, "/"), Token: token, Client: client}
+}
+
+// WithSSHHost sets the host of the ssh clone urls. An empty host keeps Host.
+func (p *GiteaProvider) WithSSHHost(sshHost string) *GiteaProvider {
+	p.SSHHost = sshHost
+	return p
+}
+
+// giteaRepo is the part of a Gitea repository response we use
+type giteaRepo struct {
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	Owner    struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+	CloneURL      string    `json:"clone_url"`
+	SSHURL        string    `json:"ssh_url"`
+	DefaultBranch string    `json:"default_branch"`
+	Private       bool      `json:"private"`
+	Internal      bool      `json:"internal"`
+	Archived      bool      `json:"archived"`
+	Fork          bool      `json:"fork"`
+	Template      bool      `json:"template"`
+	Language      string    `json:"language"`
+	Topics        []string  `json:"topics"`
+	Size          int       `json:"size"` // in KB
+	StarsCount    int       `json:"stars_count"`
+	ForksCount    int       `json:"forks_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ListRepos lists all the repositories of an organization or of a user, Me listing the ones the token can access.
+// The Gitea API can neither sort these lists nor filter them by affiliation.
+func (p *GiteaProvider) ListRepos(ctx context.Context, owner string, _ ListOptions) ([]*Repository, error) {
+	if owner == Me {
+		repos, err := p.list(ctx, p.BaseURL+"/user/repos")
+		if err != nil {
+			return nil, fmt.Errorf("error occurred while fetching the repositories of the authenticated user: %w", err)
+		}
+		return repos, nil
+	}
+
+	repos, err := p.list(ctx, fmt.Sprintf("%s/orgs/%s/repos", p.BaseURL, url.PathEscape(owner)))
+	if isNotFound(err) {
+		// not an org
+		repos, err = p.list(ctx, fmt.Sprintf("%s/users/%s/repos", p.BaseURL, url.PathEscape(owner)))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while fetching the repositories of '%s': %w", owner, err)
+	}
+	return repos, nil
+}
+
+// list pages through an endpoint returning a list of repositories
+func (p *GiteaProvider) list(ctx context.Context, endpoint string) ([]*Repository, error) {
+	var repos []*Repository
+	for page := 1; ; page++ {
+		var pageRepos []json.RawMessage
+		resp, err := getJSON(ctx, p.Client, fmt.Sprintf("%s?page=%d&limit=%d", endpoint, page, giteaPageSize), p.headers(), &pageRepos)
+		if err != nil {
+			return nil, err
+		}
+		for _, raw := range pageRepos {
+			repo, err := p.decode(raw)
+			if err != nil {
+				return nil, err
+			}
+			repos = append(repos, repo)
+		}
+
+		// servers can cap the page size lower than asked, so the total count is more reliable than short pages
+		total, err := strconv.Atoi(resp.Header.Get("X-Total-Count"))
+		if len(pageRepos) == 0 || (err == nil && len(repos) >= total) || (err != nil && len(pageRepos) < giteaPageSize) {
+			break
+		}
+	}
+
+	return repos, nil
+}
+
+// GetRepo returns the metadata of a single repository
+func (p *GiteaProvider) GetRepo(ctx context.Context, owner, name string) (*Repository, error) {
+	endpoint := fmt.Sprintf("%s/repos/%s/%s", p.BaseURL, url.PathEscape(owner), url.PathEscape(name))
+
+	var raw json.RawMessage
+	if _, err := getJSON(ctx, p.Client, endpoint, p.headers(), &raw); err != nil {
+		if isNotFound(err) {
+			return nil, fmt.Errorf("repo '%s/%s' not found on %s: %w", owner, name, p.Host, err)
+		}
+		return nil, fmt.Errorf("error occurred while fetching repo '%s/%s': %w", owner, name, err)
+	}
+	return p.decode(raw)
+}
+
+// decode converts the JSON of a repository, keeping it as the raw metadata
+func (p *GiteaProvider) decode(raw json.RawMessage) (*Repository, error) {
+	var decoded giteaRepo
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, fmt.Errorf("can't decode a Gitea repository: %w", err)
+	}
+	repo := p.convert(&decoded)
+	repo.Raw = raw
+	return repo, nil
+}
+
+func (p *GiteaProvider) CloneURLs(owner, name string) CloneURLs {
+	return defaultCloneURLs(p.Host, p.SSHHost, owner, name)
+}
+
+func (p *GiteaProvider) headers() map[string]string {
+	if p.Token == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "token " + p.Token}
+}
+
+func (p *GiteaProvider) convert(repo *giteaRepo) *Repository {
+	visibility := "public"
+	switch {
+	case repo.Internal:
+		visibility = "internal"
+	case repo.Private:
+		visibility = "private"
+	}
+
+	return &Repository{
+		Host:          p.Host,
+		Owner:         repo.Owner.Login,
+		Name:          repo.Name,
+		FullName:      repo.FullName,
+		CloneURL:      repo.CloneURL,
+		SSHURL:        repo.SSHURL,
+		DefaultBranch: repo.DefaultBranch,
+		Visibility:    visibility,
+		Archived:      repo.Archived,
+		Fork:          repo.Fork,
+		Template:      repo.Template,
+		Language:      repo.Language,
+		Topics:        repo.Topics,
+		Size:          repo.Size,
+		Stars:         repo.StarsCount,
+		Forks:         repo.ForksCount,
+		CreatedAt:     repo.CreatedAt,
+		UpdatedAt:     repo.UpdatedAt,
+		PushedAt:      repo.UpdatedAt,
+	}
+}
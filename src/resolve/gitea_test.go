@@ -0,0 +1,86 @@
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestGiteaProvider_ListRepos(t *testing.T) {
+	const total = 12
+	const serverPageSize = 5 // lower than asked for, like a server with a low MAX_RESPONSE_ITEMS
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/orgs/acme/repos" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		var repos []map[string]any
+		for i := (page - 1) * serverPageSize; i < page*serverPageSize && i < total; i++ {
+			repos = append(repos, map[string]any{
+				"name":      fmt.Sprintf("repo-%d", i),
+				"full_name": fmt.Sprintf("acme/repo-%d", i),
+				"owner":     map[string]any{"login": "acme"},
+				"private":   i%2 == 0,
+				"size":      i,
+			})
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		_ = json.NewEncoder(w).Encode(repos)
+	}))
+	defer server.Close()
+
+	provider := NewGiteaProvider("gitea.test", server.URL+"/api/v1", "secret", server.Client())
+	repos, err := provider.ListRepos(context.Background(), "acme", ListOptions{})
+	if err != nil {
+		t.Fatalf("ListRepos() returned an error: %v", err)
+	}
+
+	if len(repos) != total {
+		t.Fatalf("ListRepos() returned %d repos, expected %d", len(repos), total)
+	}
+	if repos[0].Visibility != "private" || repos[1].Visibility != "public" || repos[11].Size != 11 {
+		t.Errorf("unexpected conversion: %+v, %+v", repos[0], repos[1])
+	}
+	if urls := provider.CloneURLs("acme", "repo-0"); urls.SSH != "git@gitea.test:acme/repo-0.git" {
+		t.Errorf("CloneURLs() = %+v", urls)
+	}
+}
+
+func TestGiteaProvider_ListUserRepos(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/users/jane/repos", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"name": "dotfiles", "owner": {"login": "jane"}}]`)
+	})
+	mux.HandleFunc("/api/v1/user/repos", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"name": "dotfiles", "owner": {"login": "jane"}}, {"name": "shared", "owner": {"login": "acme"}}]`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := NewGiteaProvider("gitea.test", server.URL+"/api/v1", "secret", server.Client())
+	repos, err := provider.ListRepos(context.Background(), "jane", ListOptions{})
+	if err != nil {
+		t.Fatalf("ListRepos() returned an error for a user: %v", err)
+	}
+	if len(repos) != 1 || repos[0].Name != "dotfiles" {
+		t.Errorf("ListRepos() = %v, expected jane/dotfiles", repos)
+	}
+
+	repos, err = provider.ListRepos(context.Background(), Me, ListOptions{})
+	if err != nil {
+		t.Fatalf("ListRepos() returned an error for %s: %v", Me, err)
+	}
+	if len(repos) != 2 {
+		t.Errorf("ListRepos() returned %d repos for %s, expected 2", len(repos), Me)
+	}
+}
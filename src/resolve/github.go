@@ -0,0 +1,228 @@
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+)
+
+// GitHubProvider lists repositories through the GitHub REST API, either github.com's or a GitHub Enterprise Server's,
+// or through the GraphQL API when it has a GraphQLURL
+type GitHubProvider struct {
+	Host       string
+	SSHHost    string // host of the ssh clone urls, when it differs from Host
+	GraphQLURL string // see WithGraphQL
+	Client     *github.Client
+}
+
+func NewGitHubProvider(host string, client *github.Client) *GitHubProvider {
+	return &GitHubProvider{Host: host, Client: client}
+}
+
+// WithSSHHost sets the host of the ssh clone urls. An empty host keeps Host.
+func (p *GitHubProvider) WithSSHHost(sshHost string) *GitHubProvider {
+	p.SSHHost = sshHost
+	return p
+}
+
+func newGitHubClient(token string, transport http.RoundTripper) *github.Client {
+	client := github.NewClient(&http.Client{Transport: transport})
+	if token != "" {
+		client = client.WithAuthToken(token)
+	}
+	return client
+}
+
+// newGitHubEnterpriseClient returns a client for github.com, or for the Enterprise Server API of any other host.
+// Enterprise API urls default to https://host/api/v3/ and uploads to the API url.
+func newGitHubEnterpriseClient(opts HostOptions) (*github.Client, error) {
+	client := newGitHubClient(opts.Token, opts.Transport)
+	if opts.APIURL == "" && (opts.Host == "" || opts.Host == "github.com") {
+		return client, nil
+	}
+
+	apiURL := opts.APIURL
+	if apiURL == "" {
+		apiURL = "https://" + opts.Host + "/api/v3/"
+	}
+	uploadURL := opts.UploadURL
+	if uploadURL == "" {
+		uploadURL = apiURL
+	}
+
+	client, err := client.WithEnterpriseURLs(apiURL, uploadURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub Enterprise urls for host '%s': %w", opts.Host, err)
+	}
+	return client, nil
+}
+
+// ListRepos lists all the repositories of an organization or of a user.
+// Me lists every repository the token can access, narrowed down by the affiliation option.
+func (p *GitHubProvider) ListRepos(ctx context.Context, owner string, opts ListOptions) ([]*Repository, error) {
+	if p.GraphQLURL != "" {
+		repos, err := p.listGraphQL(ctx, owner, opts)
+		if err == nil || ctx.Err() != nil {
+			return repos, err
+		}
+	}
+
+	query := url.Values{"sort": {"updated"}, "direction": {"desc"}}
+	if opts.Sort != "" {
+		query.Set("sort", opts.Sort)
+		query.Set("direction", opts.Direction)
+	}
+
+	if owner == Me {
+		// the type parameter can't be combined with affiliation
+		if len(opts.Affiliation) > 0 {
+			query.Set("affiliation", strings.Join(opts.Affiliation, ","))
+		}
+		repos, err := p.listRaw(ctx, "user/repos", query)
+		if err != nil {
+			return nil, fmt.Errorf("error occurred while fetching the repositories of the authenticated user: %w", err)
+		}
+		return repos, nil
+	}
+
+	query.Set("type", "all")
+	repos, err := p.listRaw(ctx, fmt.Sprintf("orgs/%s/repos", url.PathEscape(owner)), query)
+	if isNotFound(err) {
+		// not an org, the owner's own repositories are listed for users
+		query.Set("type", "owner")
+		repos, err = p.listRaw(ctx, fmt.Sprintf("users/%s/repos", url.PathEscape(owner)), query)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while fetching the repositories of '%s': %w", owner, err)
+	}
+	return repos, nil
+}
+
+// ListTeamRepos lists the repositories a team of an organization can access
+func (p *GitHubProvider) ListTeamRepos(ctx context.Context, org, team string) ([]*Repository, error) {
+	endpoint := fmt.Sprintf("orgs/%s/teams/%s/repos", url.PathEscape(org), url.PathEscape(team))
+	repos, err := p.listRaw(ctx, endpoint, url.Values{})
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while fetching the repositories of team '%s/%s': %w", org, team, err)
+	}
+	return repos, nil
+}
+
+// SearchByTopics lists the repositories of an organization or of a user tagged with all the topics, through the Search API
+func (p *GitHubProvider) SearchByTopics(ctx context.Context, owner string, topics []string) ([]*Repository, error) {
+	// the user qualifier matches the repositories owned by orgs as well. Forks are only searched with fork:true,
+	// they're kept like the listing keeps them and left to the repo filter of the org.
+	terms := []string{"user:" + owner, "fork:true"}
+	for _, topic := range topics {
+		terms = append(terms, "topic:"+topic)
+	}
+
+	return p.SearchRepos(ctx, strings.Join(terms, " "))
+}
+
+// GetRepo returns the metadata of a single repository
+func (p *GitHubProvider) GetRepo(ctx context.Context, owner, name string) (*Repository, error) {
+	if p.GraphQLURL != "" {
+		repo, err := p.getGraphQL(ctx, owner, name)
+		if err == nil || ctx.Err() != nil {
+			return repo, err
+		}
+	}
+
+	req, err := p.Client.NewRequest(http.MethodGet, fmt.Sprintf("repos/%s/%s", url.PathEscape(owner), url.PathEscape(name)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	if _, err := p.Client.Do(ctx, req, &raw); err != nil {
+		return nil, fmt.Errorf("error occurred while fetching repo '%s/%s': %w", owner, name, err)
+	}
+	return p.decode(raw)
+}
+
+// listRaw pages through an endpoint returning a list of repositories, keeping the JSON of each one
+func (p *GitHubProvider) listRaw(ctx context.Context, endpoint string, query url.Values) ([]*Repository, error) {
+	query.Set("per_page", "100")
+
+	var repos []*Repository
+	for page := 1; page != 0; {
+		if page > 1 {
+			query.Set("page", strconv.Itoa(page))
+		}
+		req, err := p.Client.NewRequest(http.MethodGet, endpoint+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var raws []json.RawMessage
+		resp, err := p.Client.Do(ctx, req, &raws)
+		if err != nil {
+			return nil, err
+		}
+		for _, raw := range raws {
+			repo, err := p.decode(raw)
+			if err != nil {
+				return nil, err
+			}
+			repos = append(repos, repo)
+		}
+		page = resp.NextPage
+	}
+
+	return repos, nil
+}
+
+// decode converts the JSON of a repository, keeping it as the raw metadata
+func (p *GitHubProvider) decode(raw json.RawMessage) (*Repository, error) {
+	var ghRepo github.Repository
+	if err := json.Unmarshal(raw, &ghRepo); err != nil {
+		return nil, fmt.Errorf("can't decode a GitHub repository: %w", err)
+	}
+	repo := p.convert(&ghRepo)
+	repo.Raw = raw
+	return repo, nil
+}
+
+func (p *GitHubProvider) CloneURLs(owner, name string) CloneURLs {
+	return defaultCloneURLs(p.Host, p.SSHHost, owner, name)
+}
+
+func (p *GitHubProvider) convert(repo *github.Repository) *Repository {
+	visibility := repo.GetVisibility()
+	if visibility == "" {
+		visibility = "public"
+		if repo.GetPrivate() {
+			visibility = "private"
+		}
+	}
+
+	return &Repository{
+		Host:          p.Host,
+		Owner:         repo.GetOwner().GetLogin(),
+		Name:          repo.GetName(),
+		FullName:      repo.GetFullName(),
+		CloneURL:      repo.GetCloneURL(),
+		SSHURL:        repo.GetSSHURL(),
+		DefaultBranch: repo.GetDefaultBranch(),
+		Visibility:    visibility,
+		Archived:      repo.GetArchived(),
+		Fork:          repo.GetFork(),
+		Template:      repo.GetIsTemplate(),
+		Language:      repo.GetLanguage(),
+		License:       repo.GetLicense().GetSPDXID(),
+		Topics:        repo.Topics,
+		Size:          repo.GetSize(),
+		Stars:         repo.GetStargazersCount(),
+		Forks:         repo.GetForksCount(),
+		CreatedAt:     repo.GetCreatedAt().Time,
+		UpdatedAt:     repo.GetUpdatedAt().Time,
+		PushedAt:      repo.GetPushedAt().Time,
+	}
+}
@@ -0,0 +1,213 @@
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// GitLabProvider lists the projects of GitLab groups through the GitLab REST API v4
+type GitLabProvider struct {
+	Host    string
+	SSHHost string // host of the ssh clone urls, when it differs from Host
+	BaseURL string // e.g. https://gitlab.com/api/v4
+	Token   string
+	Client  *http.Client
+}
+
+func NewGitLabProvider(host, baseURL, token string, client *http.Client) *GitLabProvider {
+	return &GitLabProvider{Host: host, BaseURL: strings.TrimRight(baseURL, "/"), Token: token, Client: client}
+}
+
+// WithSSHHost sets the host of the ssh clone urls. An empty host keeps Host.
+func (p *GitLabProvider) WithSSHHost(sshHost string) *GitLabProvider {
+	p.SSHHost = sshHost
+	return p
+}
+
+// gitlabProject is the part of a GitLab project response we use
+type gitlabProject struct {
+	Path              string     `json:"path"`
+	PathWithNamespace string     `json:"path_with_namespace"`
+	HTTPURLToRepo     string     `json:"http_url_to_repo"`
+	SSHURLToRepo      string     `json:"ssh_url_to_repo"`
+	DefaultBranch     string     `json:"default_branch"`
+	Visibility        string     `json:"visibility"`
+	Archived          bool       `json:"archived"`
+	ForkedFrom        *struct{}  `json:"forked_from_project"`
+	Topics            []string   `json:"topics"`
+	TagList           []string   `json:"tag_list"` // topics, before GitLab 14.5
+	StarCount         int        `json:"star_count"`
+	ForksCount        int        `json:"forks_count"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         *time.Time `json:"updated_at"`
+	LastActivityAt    time.Time  `json:"last_activity_at"`
+	Namespace         struct {
+		FullPath string `json:"full_path"`
+	} `json:"namespace"`
+	Statistics *struct {
+		RepositorySize int64 `json:"repository_size"` // in bytes
+	} `json:"statistics"`
+}
+
+// gitlabOrderBy maps the generic sort fields to GitLab's order_by values
+var gitlabOrderBy = map[string]string{
+	"created":   "created_at",
+	"updated":   "updated_at",
+	"pushed":    "last_activity_at",
+	"full_name": "path",
+}
+
+// ListRepos lists all the projects of a group, including the ones in its subgroups, or the projects of a user.
+// Me lists the projects the token's user is a member of, or only the ones they own when the affiliation is owner.
+// GitLab doesn't tell collaborator projects from group ones.
+func (p *GitLabProvider) ListRepos(ctx context.Context, owner string, opts ListOptions) ([]*Repository, error) {
+	query := url.Values{
+		"statistics": {"true"},
+		"per_page":   {"100"},
+	}
+	if orderBy, ok := gitlabOrderBy[opts.Sort]; ok {
+		query.Set("order_by", orderBy)
+		query.Set("sort", opts.Direction)
+	}
+
+	if owner == Me {
+		if slices.Equal(opts.Affiliation, []string{AffiliationOwner}) {
+			query.Set("owned", "true")
+		} else {
+			query.Set("membership", "true")
+		}
+		repos, err := p.list(ctx, p.BaseURL+"/projects", query)
+		if err != nil {
+			return nil, fmt.Errorf("error occurred while fetching the projects of the authenticated user: %w", err)
+		}
+		return repos, nil
+	}
+
+	repos, err := p.ownerProjects(ctx, owner, query)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while fetching the projects of '%s': %w", owner, err)
+	}
+	return repos, nil
+}
+
+// SearchByTopics lists the projects of a group, or of a user, tagged with all the topics
+func (p *GitLabProvider) SearchByTopics(ctx context.Context, owner string, topics []string) ([]*Repository, error) {
+	query := url.Values{
+		"statistics": {"true"},
+		"per_page":   {"100"},
+		"topic":      {strings.Join(topics, ",")},
+	}
+
+	repos, err := p.ownerProjects(ctx, owner, query)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while fetching the projects of '%s' by topic: %w", owner, err)
+	}
+	return repos, nil
+}
+
+// ownerProjects lists the projects of a group and of its subgroups, falling back to the projects of a user
+func (p *GitLabProvider) ownerProjects(ctx context.Context, owner string, query url.Values) ([]*Repository, error) {
+	groupQuery := maps.Clone(query)
+	groupQuery.Set("include_subgroups", "true")
+	repos, err := p.list(ctx, fmt.Sprintf("%s/groups/%s/projects", p.BaseURL, url.PathEscape(owner)), groupQuery)
+	if isNotFound(err) {
+		// not a group, try the personal namespace of a user
+		repos, err = p.list(ctx, fmt.Sprintf("%s/users/%s/projects", p.BaseURL, url.PathEscape(owner)), query)
+	}
+	return repos, err
+}
+
+// list pages through an endpoint returning a list of projects
+func (p *GitLabProvider) list(ctx context.Context, endpoint string, query url.Values) ([]*Repository, error) {
+	var repos []*Repository
+	for page := "1"; page != ""; {
+		query.Set("page", page)
+
+		var raws []json.RawMessage
+		resp, err := getJSON(ctx, p.Client, endpoint+"?"+query.Encode(), p.headers(), &raws)
+		if err != nil {
+			return nil, err
+		}
+		for _, raw := range raws {
+			repo, err := p.decode(raw)
+			if err != nil {
+				return nil, err
+			}
+			repos = append(repos, repo)
+		}
+		page = resp.Header.Get("X-Next-Page")
+	}
+
+	return repos, nil
+}
+
+// GetRepo returns the metadata of a single project
+func (p *GitLabProvider) GetRepo(ctx context.Context, owner, name string) (*Repository, error) {
+	endpoint := fmt.Sprintf("%s/projects/%s?statistics=true", p.BaseURL, url.PathEscape(owner+"/"+name))
+
+	var raw json.RawMessage
+	if _, err := getJSON(ctx, p.Client, endpoint, p.headers(), &raw); err != nil {
+		return nil, fmt.Errorf("error occurred while fetching project '%s/%s': %w", owner, name, err)
+	}
+	return p.decode(raw)
+}
+
+// decode converts the JSON of a project, keeping it as the raw metadata
+func (p *GitLabProvider) decode(raw json.RawMessage) (*Repository, error) {
+	var project gitlabProject
+	if err := json.Unmarshal(raw, &project); err != nil {
+		return nil, fmt.Errorf("can't decode a GitLab project: %w", err)
+	}
+	repo := p.convert(&project)
+	repo.Raw = raw
+	return repo, nil
+}
+
+func (p *GitLabProvider) CloneURLs(owner, name string) CloneURLs {
+	return defaultCloneURLs(p.Host, p.SSHHost, owner, name)
+}
+
+func (p *GitLabProvider) headers() map[string]string {
+	if p.Token == "" {
+		return nil
+	}
+	return map[string]string{"PRIVATE-TOKEN": p.Token}
+}
+
+func (p *GitLabProvider) convert(project *gitlabProject) *Repository {
+	repo := &Repository{
+		Host:          p.Host,
+		Owner:         project.Namespace.FullPath,
+		Name:          project.Path,
+		FullName:      project.PathWithNamespace,
+		CloneURL:      project.HTTPURLToRepo,
+		SSHURL:        project.SSHURLToRepo,
+		DefaultBranch: project.DefaultBranch,
+		Visibility:    project.Visibility,
+		Archived:      project.Archived,
+		Fork:          project.ForkedFrom != nil,
+		Topics:        project.Topics,
+		Stars:         project.StarCount,
+		Forks:         project.ForksCount,
+		CreatedAt:     project.CreatedAt,
+		UpdatedAt:     project.LastActivityAt,
+		PushedAt:      project.LastActivityAt,
+	}
+	if len(repo.Topics) == 0 {
+		repo.Topics = project.TagList
+	}
+	if project.UpdatedAt != nil {
+		repo.UpdatedAt = *project.UpdatedAt
+	}
+	if project.Statistics != nil {
+		repo.Size = int(project.Statistics.RepositorySize / 1024)
+	}
+	return repo
+}
@@ -0,0 +1,95 @@
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGitLabProvider_ListRepos(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/groups/acme%2Fplatform/projects", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("order_by") != "last_activity_at" || r.URL.Query().Get("sort") != "desc" {
+			t.Errorf("unexpected ordering query: %s", r.URL.RawQuery)
+		}
+		if r.URL.Query().Get("page") == "1" {
+			w.Header().Set("X-Next-Page", "2")
+			fmt.Fprint(w, `[{"path": "api", "path_with_namespace": "acme/platform/api",
+				"http_url_to_repo": "https://gitlab.test/acme/platform/api.git",
+				"ssh_url_to_repo": "git@gitlab.test:acme/platform/api.git",
+				"default_branch": "main", "visibility": "internal", "topics": ["go"],
+				"star_count": 3, "namespace": {"full_path": "acme/platform"},
+				"statistics": {"repository_size": 2097152}}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"path": "web", "path_with_namespace": "acme/platform/web", "archived": true,
+			"forked_from_project": {"id": 1}, "tag_list": ["js"], "namespace": {"full_path": "acme/platform"}}]`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := NewGitLabProvider("gitlab.test", server.URL+"/api/v4", "secret", server.Client())
+	repos, err := provider.ListRepos(context.Background(), "acme/platform", ListOptions{Sort: "pushed", Direction: "desc"})
+	if err != nil {
+		t.Fatalf("ListRepos() returned an error: %v", err)
+	}
+
+	if len(repos) != 2 {
+		t.Fatalf("ListRepos() returned %d repos, expected 2", len(repos))
+	}
+	api, web := repos[0], repos[1]
+	if api.Name != "api" || api.Owner != "acme/platform" || api.Visibility != "internal" || api.Size != 2048 {
+		t.Errorf("unexpected conversion of the api project: %+v", api)
+	}
+	if api.SSHURL != "git@gitlab.test:acme/platform/api.git" || api.DefaultBranch != "main" || api.Stars != 3 {
+		t.Errorf("unexpected conversion of the api project: %+v", api)
+	}
+	if !web.Archived || !web.Fork || len(web.Topics) != 1 || web.Topics[0] != "js" {
+		t.Errorf("unexpected conversion of the web project: %+v", web)
+	}
+}
+
+func TestGitLabProvider_GetRepoError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	provider := NewGitLabProvider("gitlab.test", server.URL+"/api/v4", "", server.Client())
+	if _, err := provider.GetRepo(context.Background(), "acme", "missing"); err == nil {
+		t.Errorf("GetRepo() did not return an error for a missing project")
+	}
+}
+
+func TestGitLabProvider_ListUserProjects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/users/jane/projects", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("include_subgroups") {
+			t.Errorf("unexpected user query: %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `[{"path": "dotfiles", "namespace": {"full_path": "jane"}}]`)
+	})
+	mux.HandleFunc("/api/v4/projects", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("owned") != "true" || r.URL.Query().Has("membership") {
+			t.Errorf("unexpected authenticated user query: %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `[{"path": "dotfiles", "namespace": {"full_path": "jane"}}]`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := NewGitLabProvider("gitlab.test", server.URL+"/api/v4", "secret", server.Client())
+	for _, owner := range []string{"jane", Me} {
+		repos, err := provider.ListRepos(context.Background(), owner, ListOptions{Affiliation: []string{AffiliationOwner}})
+		if err != nil {
+			t.Fatalf("ListRepos(%s) returned an error: %v", owner, err)
+		}
+		if len(repos) != 1 || repos[0].Owner != "jane" {
+			t.Errorf("ListRepos(%s) = %v, expected jane/dotfiles", owner, repos)
+		}
+	}
+}
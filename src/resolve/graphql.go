@@ -0,0 +1,298 @@
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// graphQLPageSize is the number of repositories asked for per GraphQL page, the most the API allows
+const graphQLPageSize = 100
+
+// graphQLRepoFields are the repository fields fetched through GraphQL, in a single call per page
+const graphQLRepoFields = `
+fragment repo on Repository {
+  name
+  nameWithOwner
+  owner { login }
+  url
+  sshUrl
+  defaultBranchRef { name target { oid } }
+  visibility
+  isArchived
+  isFork
+  isTemplate
+  primaryLanguage { name }
+  languages(first: 20, orderBy: {field: SIZE, direction: DESC}) { nodes { name } }
+  repositoryTopics(first: 20) { nodes { topic { name } } }
+  licenseInfo { spdxId }
+  diskUsage
+  stargazerCount
+  forkCount
+  createdAt
+  updatedAt
+  pushedAt
+}`
+
+const graphQLOwnerReposQuery = `
+query($owner: String!, $cursor: String, $orderBy: RepositoryOrder) {
+  repositoryOwner(login: $owner) {
+    repositories(first: 100, after: $cursor, ownerAffiliations: [OWNER], orderBy: $orderBy) {
+      pageInfo { hasNextPage endCursor }
+      nodes { ...repo }
+    }
+  }
+}` + graphQLRepoFields
+
+const graphQLViewerReposQuery = `
+query($cursor: String, $orderBy: RepositoryOrder, $affiliations: [RepositoryAffiliation]) {
+  viewer {
+    repositories(first: 100, after: $cursor, affiliations: $affiliations, ownerAffiliations: [OWNER, COLLABORATOR, ORGANIZATION_MEMBER], orderBy: $orderBy) {
+      pageInfo { hasNextPage endCursor }
+      nodes { ...repo }
+    }
+  }
+}` + graphQLRepoFields
+
+const graphQLRepoQuery = `
+query($owner: String!, $name: String!) {
+  repository(owner: $owner, name: $name) { ...repo }
+}` + graphQLRepoFields
+
+// graphQLViewerAffiliations are the affiliations of the viewer's repositories listed when none are configured,
+// every repository the token can access, like the REST API lists them
+var graphQLViewerAffiliations = []string{"OWNER", "COLLABORATOR", "ORGANIZATION_MEMBER"}
+
+// graphQLOrderFields maps the generic sort fields to GraphQL's RepositoryOrderField values
+var graphQLOrderFields = map[string]string{
+	"created":   "CREATED_AT",
+	"updated":   "UPDATED_AT",
+	"pushed":    "PUSHED_AT",
+	"full_name": "NAME",
+}
+
+// graphQLRepo is the part of a GraphQL repository we use
+type graphQLRepo struct {
+	Name          string `json:"name"`
+	NameWithOwner string `json:"nameWithOwner"`
+	Owner         struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+	URL              string `json:"url"`
+	SSHURL           string `json:"sshUrl"`
+	DefaultBranchRef *struct {
+		Name   string `json:"name"`
+		Target struct {
+			OID string `json:"oid"`
+		} `json:"target"`
+	} `json:"defaultBranchRef"`
+	Visibility      string `json:"visibility"`
+	IsArchived      bool   `json:"isArchived"`
+	IsFork          bool   `json:"isFork"`
+	IsTemplate      bool   `json:"isTemplate"`
+	PrimaryLanguage *struct {
+		Name string `json:"name"`
+	} `json:"primaryLanguage"`
+	Languages struct {
+		Nodes []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+	} `json:"languages"`
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct {
+				Name string `json:"name"`
+			} `json:"topic"`
+		} `json:"nodes"`
+	} `json:"repositoryTopics"`
+	LicenseInfo *struct {
+		SPDXID string `json:"spdxId"`
+	} `json:"licenseInfo"`
+	DiskUsage      int       `json:"diskUsage"` // in KB
+	StargazerCount int       `json:"stargazerCount"`
+	ForkCount      int       `json:"forkCount"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	PushedAt       time.Time `json:"pushedAt"`
+}
+
+// graphQLConnection is a page of repositories
+type graphQLConnection struct {
+	PageInfo struct {
+		HasNextPage bool   `json:"hasNextPage"`
+		EndCursor   string `json:"endCursor"`
+	} `json:"pageInfo"`
+	Nodes []json.RawMessage `json:"nodes"`
+}
+
+// GraphQLError is an error listed in a GraphQL response
+type GraphQLError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+func (e GraphQLError) Error() string {
+	if e.Type == "" {
+		return e.Message
+	}
+	return e.Type + ": " + e.Message
+}
+
+// WithGraphQL makes the provider list and describe repositories through the GraphQL API at endpoint,
+// which returns languages, topics, the default branch head and the license of a whole page of repositories
+// in a single call. The REST API stays the fallback when a GraphQL call fails. An empty endpoint only uses REST.
+func (p *GitHubProvider) WithGraphQL(endpoint string) *GitHubProvider {
+	p.GraphQLURL = endpoint
+	return p
+}
+
+// graphQLURL returns the GraphQL endpoint matching the REST API of a client:
+// https://api.github.com/graphql for github.com and https://host/api/graphql for GitHub Enterprise Server
+func graphQLURL(restURL string) string {
+	if base, ok := strings.CutSuffix(restURL, "/v3/"); ok {
+		return base + "/graphql"
+	}
+	return strings.TrimSuffix(restURL, "/") + "/graphql"
+}
+
+// listGraphQL lists the repositories of an owner, or of Me, a page of 100 repositories per call
+func (p *GitHubProvider) listGraphQL(ctx context.Context, owner string, opts ListOptions) ([]*Repository, error) {
+	variables := map[string]any{}
+	if field, ok := graphQLOrderFields[opts.Sort]; ok {
+		variables["orderBy"] = map[string]string{"field": field, "direction": strings.ToUpper(opts.Direction)}
+	}
+
+	query, root := graphQLOwnerReposQuery, "repositoryOwner"
+	if owner == Me {
+		query, root = graphQLViewerReposQuery, "viewer"
+		// the owner affiliations are pinned to all of them in the query, the affiliation option only narrows down
+		// the viewer's own affiliation to the repositories
+		affiliations := graphQLViewerAffiliations
+		if len(opts.Affiliation) > 0 {
+			affiliations = make([]string, len(opts.Affiliation))
+			for i, a := range opts.Affiliation {
+				affiliations[i] = strings.ToUpper(a)
+			}
+		}
+		variables["affiliations"] = affiliations
+	} else {
+		variables["owner"] = owner
+	}
+
+	var repos []*Repository
+	for {
+		var data map[string]*struct {
+			Repositories graphQLConnection `json:"repositories"`
+		}
+		if err := p.graphQL(ctx, query, variables, &data); err != nil {
+			return nil, err
+		}
+		if data[root] == nil {
+			return nil, fmt.Errorf("owner '%s' not found", owner)
+		}
+
+		conn := data[root].Repositories
+		for _, raw := range conn.Nodes {
+			repo, err := p.decodeGraphQL(raw)
+			if err != nil {
+				return nil, err
+			}
+			repos = append(repos, repo)
+		}
+		if !conn.PageInfo.HasNextPage {
+			return repos, nil
+		}
+		variables["cursor"] = conn.PageInfo.EndCursor
+	}
+}
+
+// getGraphQL returns the metadata of a single repository
+func (p *GitHubProvider) getGraphQL(ctx context.Context, owner, name string) (*Repository, error) {
+	var data struct {
+		Repository json.RawMessage `json:"repository"`
+	}
+	if err := p.graphQL(ctx, graphQLRepoQuery, map[string]any{"owner": owner, "name": name}, &data); err != nil {
+		return nil, err
+	}
+	if len(data.Repository) == 0 || string(data.Repository) == "null" {
+		return nil, fmt.Errorf("repo '%s/%s' not found", owner, name)
+	}
+	return p.decodeGraphQL(data.Repository)
+}
+
+// graphQL posts a query and decodes the data of the response into out
+func (p *GitHubProvider) graphQL(ctx context.Context, query string, variables map[string]any, out any) error {
+	req, err := p.Client.NewRequest(http.MethodPost, p.GraphQLURL, map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []GraphQLError  `json:"errors"`
+	}
+	if _, err := p.Client.Do(ctx, req, &resp); err != nil {
+		return err
+	}
+	if len(resp.Errors) > 0 {
+		errs := make([]error, len(resp.Errors))
+		for i, e := range resp.Errors {
+			errs[i] = e
+		}
+		return errors.Join(errs...)
+	}
+
+	if err := json.Unmarshal(resp.Data, out); err != nil {
+		return fmt.Errorf("can't decode the GraphQL response: %w", err)
+	}
+	return nil
+}
+
+// decodeGraphQL converts the JSON of a GraphQL repository, keeping it as the raw metadata
+func (p *GitHubProvider) decodeGraphQL(raw json.RawMessage) (*Repository, error) {
+	var node graphQLRepo
+	if err := json.Unmarshal(raw, &node); err != nil {
+		return nil, fmt.Errorf("can't decode a GitHub GraphQL repository: %w", err)
+	}
+
+	repo := &Repository{
+		Host:       p.Host,
+		Owner:      node.Owner.Login,
+		Name:       node.Name,
+		FullName:   node.NameWithOwner,
+		CloneURL:   node.URL + ".git",
+		SSHURL:     node.SSHURL,
+		Visibility: strings.ToLower(node.Visibility),
+		Archived:   node.IsArchived,
+		Fork:       node.IsFork,
+		Template:   node.IsTemplate,
+		Size:       node.DiskUsage,
+		Stars:      node.StargazerCount,
+		Forks:      node.ForkCount,
+		CreatedAt:  node.CreatedAt,
+		UpdatedAt:  node.UpdatedAt,
+		PushedAt:   node.PushedAt,
+		Raw:        raw,
+	}
+	if node.DefaultBranchRef != nil {
+		repo.DefaultBranch = node.DefaultBranchRef.Name
+		repo.HeadCommit = node.DefaultBranchRef.Target.OID
+	}
+	if node.PrimaryLanguage != nil {
+		repo.Language = node.PrimaryLanguage.Name
+	}
+	for _, l := range node.Languages.Nodes {
+		repo.Languages = append(repo.Languages, l.Name)
+	}
+	for _, t := range node.RepositoryTopics.Nodes {
+		repo.Topics = append(repo.Topics, t.Topic.Name)
+	}
+	if node.LicenseInfo != nil {
+		repo.License = node.LicenseInfo.SPDXID
+	}
+	return repo, nil
+}
@@ -0,0 +1,211 @@
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/florinutz/git-intel/src/httpcache"
+)
+
+// serveFixture writes a recorded API response from testdata
+func serveFixture(t *testing.T, w http.ResponseWriter, name string) {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture %s: %v", name, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+// graphQLRequest is the body of a GraphQL call
+type graphQLRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables"`
+}
+
+func TestGitHubProvider_ListReposGraphQL(t *testing.T) {
+	var calls []graphQLRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/graphql" || r.Method != http.MethodPost {
+			t.Errorf("unexpected call to %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		var req graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("Failed to decode the GraphQL request: %v", err)
+		}
+		calls = append(calls, req)
+
+		if req.Variables["cursor"] == nil {
+			serveFixture(t, w, "github_graphql_org_repos_page1.json")
+			return
+		}
+		serveFixture(t, w, "github_graphql_org_repos_page2.json")
+	}))
+	defer server.Close()
+
+	provider := NewGitHubProvider("github.com", newTestGitHubClient(t, server)).WithGraphQL(server.URL + "/graphql")
+	repos, err := provider.ListRepos(context.Background(), "acme", ListOptions{Sort: "pushed", Direction: "desc"})
+	if err != nil {
+		t.Fatalf("ListRepos() returned an error: %v", err)
+	}
+
+	if len(calls) != 2 {
+		t.Fatalf("ListRepos() made %d GraphQL calls, expected one per page", len(calls))
+	}
+	if calls[0].Variables["owner"] != "acme" || calls[1].Variables["cursor"] != "Y3Vyc29yOnYyOpHOAAHbqQ==" {
+		t.Errorf("unexpected variables: %v, %v", calls[0].Variables, calls[1].Variables)
+	}
+	if order, _ := calls[0].Variables["orderBy"].(map[string]any); order["field"] != "PUSHED_AT" || order["direction"] != "DESC" {
+		t.Errorf("unexpected order: %v", calls[0].Variables["orderBy"])
+	}
+
+	if len(repos) != 2 {
+		t.Fatalf("ListRepos() returned %d repos, expected 2", len(repos))
+	}
+	api, legacy := repos[0], repos[1]
+	if api.HeadCommit != "8f3c2a1d9b7e4f6a0c5d2e1b3a4f5c6d7e8f9a0b" || api.DefaultBranch != "main" || api.License != "Apache-2.0" {
+		t.Errorf("unexpected conversion of api: %+v", api)
+	}
+	if !slices.Equal(api.Languages, []string{"Go", "Shell", "Dockerfile"}) || !slices.Equal(api.Topics, []string{"backend", "grpc"}) {
+		t.Errorf("unexpected languages or topics of api: %v, %v", api.Languages, api.Topics)
+	}
+	if api.Visibility != "private" || api.CloneURL != "https://github.com/acme/api.git" || api.Size != 5120 || len(api.Raw) == 0 {
+		t.Errorf("unexpected conversion of api: %+v", api)
+	}
+	if !legacy.Archived || !legacy.Fork || legacy.Visibility != "internal" || legacy.DefaultBranch != "" || legacy.License != "" {
+		t.Errorf("unexpected conversion of legacy-web: %+v", legacy)
+	}
+}
+
+func TestGitHubProvider_ListViewerReposGraphQL(t *testing.T) {
+	tests := []struct {
+		name        string
+		affiliation []string
+		expected    []any
+	}{
+		{name: "every affiliation by default", expected: []any{"OWNER", "COLLABORATOR", "ORGANIZATION_MEMBER"}},
+		{name: "configured affiliation", affiliation: []string{"owner", "organization_member"}, expected: []any{"OWNER", "ORGANIZATION_MEMBER"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var call graphQLRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&call); err != nil {
+					t.Fatalf("Failed to decode the GraphQL request: %v", err)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"data":{"viewer":{"repositories":{"pageInfo":{"hasNextPage":false},"nodes":[]}}}}`))
+			}))
+			defer server.Close()
+
+			provider := NewGitHubProvider("github.com", newTestGitHubClient(t, server)).WithGraphQL(server.URL + "/graphql")
+			if _, err := provider.ListRepos(context.Background(), Me, ListOptions{Affiliation: tt.affiliation}); err != nil {
+				t.Fatalf("ListRepos() returned an error: %v", err)
+			}
+
+			if affiliations, _ := call.Variables["affiliations"].([]any); !slices.Equal(affiliations, tt.expected) {
+				t.Errorf("ListRepos() asked for the affiliations %v, expected %v", call.Variables["affiliations"], tt.expected)
+			}
+			if !strings.Contains(call.Query, "ownerAffiliations: [OWNER, COLLABORATOR, ORGANIZATION_MEMBER]") {
+				t.Errorf("ListRepos() does not list the repositories of every owner:\n%s", call.Query)
+			}
+		})
+	}
+}
+
+func TestGitHubProvider_GraphQLFallsBackToREST(t *testing.T) {
+	var restCalled bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/graphql":
+			serveFixture(t, w, "github_graphql_forbidden.json")
+		case "/orgs/acme/repos":
+			restCalled = true
+			serveFixture(t, w, "github_rest_org_repos.json")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewGitHubProvider("github.com", newTestGitHubClient(t, server)).WithGraphQL(server.URL + "/graphql")
+	repos, err := provider.ListRepos(context.Background(), "acme", ListOptions{})
+	if err != nil {
+		t.Fatalf("ListRepos() returned an error: %v", err)
+	}
+
+	if !restCalled {
+		t.Errorf("the REST API was not used after the GraphQL error")
+	}
+	if len(repos) != 1 || repos[0].License != "Apache-2.0" || repos[0].DefaultBranch != "main" || repos[0].HeadCommit != "" {
+		t.Errorf("unexpected REST conversion: %+v", repos)
+	}
+}
+
+func TestNewProvider_CachedListsThroughREST(t *testing.T) {
+	var listed, revalidated int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/orgs/acme/repos":
+			listed++
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				revalidated++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			serveFixture(t, w, "github_rest_org_repos.json")
+		default:
+			t.Errorf("unexpected call to %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cache, err := httpcache.New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create the cache: %v", err)
+	}
+	provider, err := NewProvider(KindGitHub, HostOptions{Host: "git.corp.example", APIURL: server.URL + "/api/v3/", Token: "secret", Transport: cache, Cached: true})
+	if err != nil {
+		t.Fatalf("NewProvider() returned an error: %v", err)
+	}
+
+	for run := 1; run <= 2; run++ {
+		repos, err := provider.ListRepos(context.Background(), "acme", ListOptions{})
+		if err != nil {
+			t.Fatalf("ListRepos() returned an error on run %d: %v", run, err)
+		}
+		if len(repos) != 1 || repos[0].Name != "api" {
+			t.Errorf("ListRepos() returned %v on run %d, expected acme/api", repos, run)
+		}
+	}
+
+	if listed != 2 || revalidated != 1 {
+		t.Errorf("the listing was requested %d times and revalidated %d times, expected twice and revalidated the second time", listed, revalidated)
+	}
+	if stats := cache.Stats(); stats.Revalidated != 1 {
+		t.Errorf("the second run was not answered from the cache: %s", stats)
+	}
+}
+
+func TestGraphQLURL(t *testing.T) {
+	for rest, expected := range map[string]string{
+		"https://api.github.com/":          "https://api.github.com/graphql",
+		"https://git.corp.example/api/v3/": "https://git.corp.example/api/graphql",
+	} {
+		if got := graphQLURL(rest); got != expected {
+			t.Errorf("graphQLURL(%q) = %q, expected %q", rest, got, expected)
+		}
+	}
+}
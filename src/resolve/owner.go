@@ -0,0 +1,45 @@
+package resolve
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-github/v62/github"
+)
+
+// Me is the owner standing for the authenticated user: listing it returns every repository the token can access
+const Me = "@me"
+
+// Affiliations narrow down the repositories of Me
+const (
+	AffiliationOwner              = "owner"               // repositories owned by the user
+	AffiliationCollaborator       = "collaborator"        // repositories the user was added to as a collaborator
+	AffiliationOrganizationMember = "organization_member" // repositories of the orgs the user is a member of
+)
+
+// ValidateAffiliation checks a list of affiliations, an empty list meaning all of them
+func ValidateAffiliation(affiliation []string) error {
+	for _, a := range affiliation {
+		switch a {
+		case AffiliationOwner, AffiliationCollaborator, AffiliationOrganizationMember:
+		default:
+			return fmt.Errorf("invalid affiliation '%s', valid values are owner, collaborator and organization_member", a)
+		}
+	}
+	return nil
+}
+
+// isNotFound tells if an API call failed because the resource doesn't exist.
+// Providers list owners as organizations first and fall back to users on it.
+func isNotFound(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusNotFound
+	}
+	var ghErr *github.ErrorResponse
+	if errors.As(err, &ghErr) && ghErr.Response != nil {
+		return ghErr.Response.StatusCode == http.StatusNotFound
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Provider lists and describes the repositories of a hosting service
+type Provider interface {
+	// ListRepos lists the repositories of an organization, group or user
+	ListRepos(ctx context.Context, owner string, opts ListOptions) ([]*Repository, error)
+	// GetRepo returns the metadata of a single repository
+	GetRepo(ctx context.Context, owner, name string) (*Repository, error)
+	// CloneURLs returns the clone urls of a repository, without calling the API
+	CloneURLs(owner, name string) CloneURLs
+}
+
+// TeamLister is implemented by the providers whose organizations have teams
+type TeamLister interface {
+	// ListTeamRepos lists the repositories a team, given by its slug, can access
+	ListTeamRepos(ctx context.Context, org, team string) ([]*Repository, error)
+}
+
+// TopicSearcher is implemented by the providers that can look up repositories by topic on the server
+type TopicSearcher interface {
+	// SearchByTopics lists the repositories of an owner tagged with every one of the topics
+	SearchByTopics(ctx context.Context, owner string, topics []string) ([]*Repository, error)
+}
+
+// ProviderKind names a hosting service API
+type ProviderKind string
+
+const (
+	KindGitHub ProviderKind = "github"
+	KindGitLab ProviderKind = "gitlab"
+	KindGitea  ProviderKind = "gitea" // also serves Forgejo, which shares the Gitea API
+)
+
+// knownHosts are the public hosts whose provider kind doesn't need to be configured
+var knownHosts = map[string]ProviderKind{
+	"github.com":   KindGitHub,
+	"gitlab.com":   KindGitLab,
+	"gitea.com":    KindGitea,
+	"codeberg.org": KindGitea,
+}
+
+// KnownKind returns the provider kind of a well known public host
+func KnownKind(host string) (ProviderKind, bool) {
+	kind, ok := knownHosts[host]
+	return kind, ok
+}
+
+// ParseProviderKind validates a configured provider kind. "forgejo" is accepted as an alias of "gitea".
+func ParseProviderKind(s string) (ProviderKind, error) {
+	switch ProviderKind(s) {
+	case KindGitHub, KindGitLab, KindGitea:
+		return ProviderKind(s), nil
+	case "forgejo":
+		return KindGitea, nil
+	}
+	return "", fmt.Errorf("unknown provider type '%s', valid values are github, gitlab, gitea and forgejo", s)
+}
+
+// HostOptions configure how a host is reached
+type HostOptions struct {
+	Host      string // the host of the web and https clone urls, e.g. git.corp.example
+	APIURL    string // defaults to the provider's API on the host
+	UploadURL string // GitHub Enterprise only, defaults to the APIURL
+	SSHHost   string // host of the ssh clone urls, defaults to Host
+	Token     string
+	Transport http.RoundTripper // carries the API calls, e.g. through a cache. Defaults to http.DefaultTransport
+	Cached    bool              // the Transport caches the GET responses and revalidates them
+}
+
+// NewProvider builds the provider of the given kind for a host
+func NewProvider(kind ProviderKind, opts HostOptions) (Provider, error) {
+	switch kind {
+	case KindGitHub:
+		client, err := newGitHubEnterpriseClient(opts)
+		if err != nil {
+			return nil, err
+		}
+		provider := NewGitHubProvider(opts.Host, client).WithSSHHost(opts.SSHHost)
+		// the GraphQL API can't be used anonymously. Its POSTs can't be revalidated either, so a cache is better
+		// served by the REST listings, which cost nothing against the rate limit when they haven't changed.
+		if opts.Token != "" && !opts.Cached {
+			provider.WithGraphQL(graphQLURL(client.BaseURL.String()))
+		}
+		return provider, nil
+	case KindGitLab:
+		apiURL := opts.APIURL
+		if apiURL == "" {
+			apiURL = "https://" + opts.Host + "/api/v4"
+		}
+		return NewGitLabProvider(opts.Host, apiURL, opts.Token, &http.Client{Transport: opts.Transport}).WithSSHHost(opts.SSHHost), nil
+	case KindGitea:
+		apiURL := opts.APIURL
+		if apiURL == "" {
+			apiURL = "https://" + opts.Host + "/api/v1"
+		}
+		return NewGiteaProvider(opts.Host, apiURL, opts.Token, &http.Client{Transport: opts.Transport}).WithSSHHost(opts.SSHHost), nil
+	}
+	return nil, fmt.Errorf("unknown provider type '%s'", kind)
+}
@@ -0,0 +1,80 @@
+package resolve
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Repository describes a repository with the metadata captured while listing it,
+// so the later stages don't have to call the API again
+type Repository struct {
+	Host          string
+	Owner         string
+	Name          string
+	FullName      string
+	CloneURL      string // https clone url
+	SSHURL        string
+	DefaultBranch string
+	HeadCommit    string // the commit the default branch points to, when the provider returns it
+	Visibility    string // public, private or internal
+	Archived      bool
+	Fork          bool
+	Template      bool
+	Language      string   // the primary language
+	Languages     []string // all the languages, largest first, when the provider returns them
+	License       string   // SPDX id
+	Topics        []string
+	Size          int // in KB
+	Stars         int
+	Forks         int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	PushedAt      time.Time
+
+	Raw     json.RawMessage // the repository as the provider's API returned it
+	Sources []string        // the config entries that asked for the repository
+}
+
+// Ref returns the canonical identity of the repository
+func (r *Repository) Ref() RepoRef {
+	return RepoRef{Host: r.Host, Owner: r.Owner, Name: r.Name}
+}
+
+// Pair returns the owner and the name of the repository.
+//
+// Deprecated: RepoPair is kept for compatibility, use the Repository fields or Ref.
+func (r *Repository) Pair() RepoPair {
+	return RepoPair{Owner: r.Owner, Repo: r.Name}
+}
+
+// key identifies the repository when de-duplicating, hosts and owners being case-insensitive
+func (r *Repository) key() string {
+	return strings.ToLower(r.Ref().String())
+}
+
+// ListOptions are hints for listing repositories. Providers apply what their API supports and ignore the rest.
+type ListOptions struct {
+	Sort      string // created, updated, pushed or full_name
+	Direction string // asc or desc
+	// Affiliation narrows down the repositories of Me to the ones owned by the user, shared with them as a collaborator
+	// and the ones of the orgs they belong to. Empty means all of them.
+	Affiliation []string
+}
+
+// CloneURLs are the urls a repository can be cloned from
+type CloneURLs struct {
+	HTTPS string
+	SSH   string
+}
+
+// defaultCloneURLs builds the clone urls most providers use. An empty sshHost means the ssh urls use host.
+func defaultCloneURLs(host, sshHost, owner, name string) CloneURLs {
+	if sshHost == "" {
+		sshHost = host
+	}
+	return CloneURLs{
+		HTTPS: "https://" + host + "/" + owner + "/" + name + ".git",
+		SSH:   "git@" + sshHost + ":" + owner + "/" + name + ".git",
+	}
+}
@@ -3,95 +3,144 @@ package resolve
 import (
 	"context"
 	"fmt"
-	"net/url"
-	"regexp"
+	"net/http"
+	"slices"
 	"strings"
-
-	"github.com/google/go-github/v62/github"
 )
 
+// RepoPair is the owner and the name of a repository.
+//
+// Deprecated: use Repository, whose Pair method gives a RepoPair.
 type RepoPair struct {
 	Owner string
 	Repo  string
 }
 
+// Resolver resolves repository and organization urls through the provider registered for their host
 type Resolver struct {
-	Client *github.Client
+	providers map[string]Provider
+}
+
+// NewResolver returns a resolver that knows github.com. A nil transport means http.DefaultTransport.
+func NewResolver(token string, transport http.RoundTripper) *Resolver {
+	r := &Resolver{providers: make(map[string]Provider)}
+	// building the github.com provider only fails for invalid Enterprise urls
+	github, _ := NewProvider(KindGitHub, HostOptions{Host: DefaultHost, Token: token, Transport: transport})
+	r.Register(DefaultHost, github)
+	return r
+}
+
+// Register makes the resolver use the provider for the urls of the host
+func (r *Resolver) Register(host string, provider Provider) {
+	r.providers[host] = provider
 }
 
-func NewResolver(token string) *Resolver {
-	client := github.NewClient(nil)
-	if token != "" {
-		client = client.WithAuthToken(token)
+// RegisterHost builds the provider of the given kind for the host and registers it
+func (r *Resolver) RegisterHost(kind ProviderKind, opts HostOptions) error {
+	provider, err := NewProvider(kind, opts)
+	if err != nil {
+		return err
 	}
-	return &Resolver{Client: client}
+	r.Register(opts.Host, provider)
+	return nil
 }
 
-func (r *Resolver) Resolve(rawURLs []string) ([][]RepoPair, error) {
-	invalidURLsPositions := r.validate(rawURLs)
+// Provider returns the provider registered for the host
+func (r *Resolver) Provider(host string) (Provider, error) {
+	provider, ok := r.providers[host]
+	if !ok {
+		return nil, fmt.Errorf("no provider configured for host '%s'", host)
+	}
+	return provider, nil
+}
+
+// Source is a config entry asking for repositories, like a repository url, an org or a search query
+type Source struct {
+	Entry string // names the entry in the Sources of the repositories it asks for
+	URL   string // a repository or an owner url, resolved through the provider of its host when List is nil
+	// List returns the repositories of the entries that aren't a plain url, like the orgs having selection rules
+	List func(ctx context.Context) ([]*Repository, error)
+}
+
+// URLSources makes a source of every url, each entry being named after its url
+func URLSources(rawURLs ...string) []Source {
+	sources := make([]Source, len(rawURLs))
+	for i, rawURL := range rawURLs {
+		sources[i] = Source{Entry: rawURL, URL: rawURL}
+	}
+	return sources
+}
+
+// Resolve turns config entries into a flat list of repositories, each listed once, in the order the entries ask
+// for them. Every repository records the entries that asked for it in its Sources, in the order they were given.
+func (r *Resolver) Resolve(ctx context.Context, sources []Source) ([]*Repository, error) {
+	invalidURLsPositions := r.validate(sources)
 	if len(invalidURLsPositions) > 0 {
 		invalidURLs := make([]string, len(invalidURLsPositions))
 		for i, pos := range invalidURLsPositions {
-			invalidURLs[i] = rawURLs[pos]
+			invalidURLs[i] = sources[pos].URL
 		}
 		return nil, fmt.Errorf("invalid URLs: %v", strings.Join(invalidURLs, ", "))
 	}
 
-	var allRepos [][]RepoPair
-	for _, rawURL := range rawURLs {
-		repos, err := r.resolveOne(rawURL)
+	var allRepos []*Repository
+	byKey := make(map[string]*Repository)
+	for _, source := range sources {
+		list := source.List
+		if list == nil {
+			list = func(ctx context.Context) ([]*Repository, error) { return r.resolveOne(ctx, source.URL) }
+		}
+		repos, err := list(ctx)
 		if err != nil {
 			return nil, err
 		}
-		allRepos = append(allRepos, repos)
+		for _, repo := range repos {
+			if seen, ok := byKey[repo.key()]; ok {
+				if !slices.Contains(seen.Sources, source.Entry) {
+					seen.Sources = append(seen.Sources, source.Entry)
+				}
+				continue
+			}
+			repo.Sources = []string{source.Entry}
+			byKey[repo.key()] = repo
+			allRepos = append(allRepos, repo)
+		}
 	}
 	return allRepos, nil
 }
 
-func (r *Resolver) resolveOne(rawURL string) ([]RepoPair, error) {
-	u, err := url.Parse(rawURL)
+func (r *Resolver) resolveOne(ctx context.Context, rawURL string) ([]*Repository, error) {
+	ref, err := ParseRepoURL(rawURL)
 	if err != nil {
-		return nil, fmt.Errorf("invalid URL: %s", rawURL)
+		return nil, err
 	}
-	parts := strings.Split(u.Path, "/")
-	if len(parts) < 2 {
-		return nil, fmt.Errorf("invalid GitHub URL: %s", rawURL)
+	provider, err := r.Provider(ref.Host)
+	if err != nil {
+		return nil, err
 	}
-	owner := parts[1]
-	if len(parts) == 2 {
+
+	if ref.IsOwner() {
 		// This is an organization URL, we need to list all the repos
-		opt := &github.RepositoryListByOrgOptions{
-			ListOptions: github.ListOptions{PerPage: 10},
-		}
-		var allRepos []RepoPair
-		for {
-			repos, resp, err := r.Client.Repositories.ListByOrg(context.Background(), owner, opt)
-			if err != nil {
-				return nil, err
-			}
-			for _, repo := range repos {
-				allRepos = append(allRepos, RepoPair{Owner: owner, Repo: *repo.Name})
-			}
-			if resp.NextPage == 0 {
-				break
-			}
-			opt.Page = resp.NextPage
-		}
-		return allRepos, nil
+		return provider.ListRepos(ctx, ref.Owner, ListOptions{})
 	} else {
 		// This is a single repo URL
-		return []RepoPair{{Owner: owner, Repo: parts[2]}}, nil
+		repo, err := provider.GetRepo(ctx, ref.Owner, ref.Name)
+		if err != nil {
+			return nil, err
+		}
+		return []*Repository{repo}, nil
 	}
 }
 
-// validate returns positions of invalid urls in the input slice
-func (r *Resolver) validate(urls []string) (invalidURLs []int) {
-	for i, rawURL := range urls {
-		if u, err := url.ParseRequestURI(rawURL); err != nil || u.Host != "github.com" {
-			matched, err := regexp.MatchString(`git@github\.com:([a-zA-Z0-9_.-]+)/([a-zA-Z0-9_.-]+)\.git`, rawURL)
-			if err != nil || !matched {
-				invalidURLs = append(invalidURLs, i)
-			}
+// validate returns positions of the sources resolved through an invalid url in the input slice
+func (r *Resolver) validate(sources []Source) (invalidURLs []int) {
+	for i, source := range sources {
+		if source.List != nil {
+			continue
+		}
+		ref, err := ParseRepoURL(source.URL)
+		if err != nil || r.providers[ref.Host] == nil {
+			invalidURLs = append(invalidURLs, i)
 		}
 	}
 	return invalidURLs
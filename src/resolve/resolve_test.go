@@ -0,0 +1,274 @@
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+)
+
+// newTestGitHubClient returns a GitHub client that talks to the test server
+func newTestGitHubClient(t *testing.T, server *httptest.Server) *github.Client {
+	t.Helper()
+
+	client := github.NewClient(server.Client())
+	baseURL, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatalf("Failed to parse the test server url: %v", err)
+	}
+	client.BaseURL = baseURL
+	return client
+}
+
+// stubProvider lists a fixed set of repository names
+type stubProvider struct {
+	host  string
+	names []string
+}
+
+func (p *stubProvider) ListRepos(_ context.Context, owner string, _ ListOptions) ([]*Repository, error) {
+	var repos []*Repository
+	for _, name := range p.names {
+		repos = append(repos, &Repository{Host: p.host, Owner: owner, Name: name})
+	}
+	return repos, nil
+}
+
+func (p *stubProvider) GetRepo(_ context.Context, owner, name string) (*Repository, error) {
+	return &Repository{Host: p.host, Owner: owner, Name: name}, nil
+}
+
+func (p *stubProvider) CloneURLs(owner, name string) CloneURLs {
+	return defaultCloneURLs(p.host, p.host, owner, name)
+}
+
+func TestResolver_SelectsProviderByHost(t *testing.T) {
+	resolver := &Resolver{providers: make(map[string]Provider)}
+	resolver.Register("gitlab.test", &stubProvider{host: "gitlab.test", names: []string{"a", "b"}})
+	resolver.Register("gitea.test", &stubProvider{host: "gitea.test", names: []string{"c"}})
+
+	resolved, err := resolver.Resolve(context.Background(), URLSources("https://gitlab.test/acme", "https://gitea.test/acme", "git@gitea.test:acme/d.git"))
+	if err != nil {
+		t.Fatalf("Resolve() returned an error: %v", err)
+	}
+
+	expected := []string{"gitlab.test/acme/a", "gitlab.test/acme/b", "gitea.test/acme/c", "gitea.test/acme/d"}
+	if len(resolved) != len(expected) {
+		t.Fatalf("Resolve() returned %d repos, expected %d", len(resolved), len(expected))
+	}
+	for i, repo := range resolved {
+		if repo.Ref().String() != expected[i] {
+			t.Errorf("repo %d is %s, expected %s", i, repo.Ref(), expected[i])
+		}
+	}
+
+	if _, err := resolver.Resolve(context.Background(), URLSources("https://unknown.test/acme")); err == nil {
+		t.Errorf("Resolve() accepted a host without a provider")
+	}
+}
+
+func TestResolver_DeduplicatesAndRecordsSources(t *testing.T) {
+	resolver := &Resolver{providers: make(map[string]Provider)}
+	resolver.Register("gitlab.test", &stubProvider{host: "gitlab.test", names: []string{"api", "web"}})
+
+	sources := []Source{
+		{Entry: "org acme", URL: "https://gitlab.test/acme"},
+		{Entry: "repo api", URL: "git@gitlab.test:acme/api.git"},
+		{Entry: "query api", List: func(context.Context) ([]*Repository, error) {
+			return []*Repository{{Host: "gitlab.test", Owner: "ACME", Name: "api"}, {Host: "gitlab.test", Owner: "acme", Name: "docs"}}, nil
+		}},
+	}
+	resolved, err := resolver.Resolve(context.Background(), sources)
+	if err != nil {
+		t.Fatalf("Resolve() returned an error: %v", err)
+	}
+
+	if len(resolved) != 3 {
+		t.Fatalf("Resolve() returned %d repos, expected api, web and docs once each", len(resolved))
+	}
+	api := resolved[0]
+	if expected := []string{"org acme", "repo api", "query api"}; !slices.Equal(api.Sources, expected) {
+		t.Errorf("api has the sources %v, expected %v", api.Sources, expected)
+	}
+	if pair := api.Pair(); pair.Owner != "acme" || pair.Repo != "api" {
+		t.Errorf("Pair() = %+v, expected acme/api", pair)
+	}
+	if !slices.Equal(resolved[1].Sources, []string{"org acme"}) {
+		t.Errorf("web has the sources %v, expected only the org", resolved[1].Sources)
+	}
+	if docs := resolved[2]; docs.Name != "docs" || !slices.Equal(docs.Sources, []string{"query api"}) {
+		t.Errorf("unexpected listed repository %+v", docs)
+	}
+}
+
+func TestGitHubProvider_ListRepos(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/orgs/acme/repos" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/orgs/acme/repos?page=2>; rel="next"`, "http://"+r.Host))
+			fmt.Fprint(w, `[{"name": "api", "full_name": "acme/api", "owner": {"login": "acme"}, "private": true,
+				"ssh_url": "git@github.com:acme/api.git", "language": "Go"}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"name": "web", "full_name": "acme/web", "owner": {"login": "acme"}, "visibility": "internal"}]`)
+	}))
+	defer server.Close()
+
+	provider := NewGitHubProvider("github.com", newTestGitHubClient(t, server))
+	repos, err := provider.ListRepos(context.Background(), "acme", ListOptions{})
+	if err != nil {
+		t.Fatalf("ListRepos() returned an error: %v", err)
+	}
+
+	if len(repos) != 2 {
+		t.Fatalf("ListRepos() returned %d repos, expected 2", len(repos))
+	}
+	if repos[0].Visibility != "private" || repos[0].Language != "Go" || repos[0].Owner != "acme" {
+		t.Errorf("unexpected conversion: %+v", repos[0])
+	}
+	if repos[1].Visibility != "internal" {
+		t.Errorf("unexpected conversion: %+v", repos[1])
+	}
+}
+
+func TestResolver_GitHubEnterprise(t *testing.T) {
+	var graphQLTried bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/graphql" {
+			// an Enterprise Server without GraphQL, the REST API is the fallback
+			graphQLTried = true
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path != "/api/v3/orgs/platform/repos" {
+			t.Errorf("the enterprise API was called at %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer corp-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `[{"name": "billing", "owner": {"login": "platform"}, "ssh_url": "git@ssh.git.corp.example:platform/billing.git"}]`)
+	}))
+	defer server.Close()
+
+	resolver := NewResolver("", nil)
+	err := resolver.RegisterHost(KindGitHub, HostOptions{
+		Host:    "git.corp.example",
+		APIURL:  server.URL,
+		SSHHost: "ssh.git.corp.example",
+		Token:   "corp-token",
+	})
+	if err != nil {
+		t.Fatalf("RegisterHost() returned an error: %v", err)
+	}
+
+	resolved, err := resolver.Resolve(context.Background(), URLSources("https://git.corp.example/platform"))
+	if err != nil {
+		t.Fatalf("Resolve() returned an error: %v", err)
+	}
+	if len(resolved) != 1 || resolved[0].Name != "billing" {
+		t.Fatalf("Resolve() = %v, expected platform/billing", resolved)
+	}
+	if !strings.Contains(string(resolved[0].Raw), `"ssh_url"`) {
+		t.Errorf("the raw metadata was not kept: %s", resolved[0].Raw)
+	}
+	if !graphQLTried {
+		t.Errorf("the GraphQL API of the enterprise server was not tried first")
+	}
+
+	provider, _ := resolver.Provider("git.corp.example")
+	urls := provider.CloneURLs("platform", "billing")
+	if urls.SSH != "git@ssh.git.corp.example:platform/billing.git" || urls.HTTPS != "https://git.corp.example/platform/billing.git" {
+		t.Errorf("CloneURLs() = %+v", urls)
+	}
+}
+
+func TestGitHubProvider_ListUserRepos(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/jane/repos":
+			if r.URL.Query().Get("type") != "owner" {
+				t.Errorf("unexpected user query: %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `[{"name": "dotfiles", "owner": {"login": "jane"}}]`)
+		case "/user/repos":
+			if r.URL.Query().Get("affiliation") != "owner,collaborator" || r.URL.Query().Has("type") {
+				t.Errorf("unexpected authenticated user query: %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `[{"name": "dotfiles", "owner": {"login": "jane"}}, {"name": "shared", "owner": {"login": "john"}}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewGitHubProvider("github.com", newTestGitHubClient(t, server))
+	repos, err := provider.ListRepos(context.Background(), "jane", ListOptions{})
+	if err != nil {
+		t.Fatalf("ListRepos() returned an error for a user: %v", err)
+	}
+	if len(repos) != 1 || repos[0].Name != "dotfiles" {
+		t.Errorf("ListRepos() = %v, expected jane/dotfiles", repos)
+	}
+
+	repos, err = provider.ListRepos(context.Background(), Me, ListOptions{Affiliation: []string{AffiliationOwner, AffiliationCollaborator}})
+	if err != nil {
+		t.Fatalf("ListRepos() returned an error for %s: %v", Me, err)
+	}
+	if len(repos) != 2 || repos[1].Owner != "john" {
+		t.Errorf("ListRepos() = %v, expected the owned and the shared repos", repos)
+	}
+
+	if _, err := provider.ListRepos(context.Background(), "nobody", ListOptions{}); err == nil {
+		t.Errorf("ListRepos() did not return an error for a missing owner")
+	}
+}
+
+func TestGitHubProvider_TeamsAndTopics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/orgs/acme/teams/platform/repos":
+			fmt.Fprint(w, `[{"name": "api", "owner": {"login": "acme"}}]`)
+		case "/search/repositories":
+			if q := r.URL.Query().Get("q"); q != "user:acme fork:true topic:backend topic:go" {
+				t.Errorf("unexpected search query: %s", q)
+			}
+			fmt.Fprint(w, `{"total_count": 1, "items": [{"name": "api", "owner": {"login": "acme"}, "topics": ["backend", "go"]}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewGitHubProvider("github.com", newTestGitHubClient(t, server))
+	repos, err := provider.ListTeamRepos(context.Background(), "acme", "platform")
+	if err != nil {
+		t.Fatalf("ListTeamRepos() returned an error: %v", err)
+	}
+	if len(repos) != 1 || repos[0].Name != "api" {
+		t.Errorf("ListTeamRepos() = %v, expected acme/api", repos)
+	}
+
+	repos, err = provider.SearchByTopics(context.Background(), "acme", []string{"backend", "go"})
+	if err != nil {
+		t.Fatalf("SearchByTopics() returned an error: %v", err)
+	}
+	if len(repos) != 1 || len(repos[0].Topics) != 2 {
+		t.Errorf("SearchByTopics() = %v, expected acme/api with its topics", repos)
+	}
+
+	if _, err := provider.ListTeamRepos(context.Background(), "acme", "missing"); err == nil {
+		t.Errorf("ListTeamRepos() did not return an error for a missing team")
+	}
+}
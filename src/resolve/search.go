@@ -0,0 +1,126 @@
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RepoSearcher is implemented by the providers that can run raw repository search queries
+type RepoSearcher interface {
+	// SearchRepos returns every repository matching a search query
+	SearchRepos(ctx context.Context, query string) ([]*Repository, error)
+}
+
+// searchResultCap is the number of results the Search API returns at most for a single query
+var searchResultCap = 1000
+
+// searchEpoch is a date before any repository was created, split date ranges start there
+var searchEpoch = time.Date(2007, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// searchTimeLayout is the timestamp format of the created qualifier
+const searchTimeLayout = "2006-01-02T15:04:05-07:00"
+
+// SearchRepos returns every repository matching a GitHub search query, like "org:acme language:go pushed:>2024-01-01".
+// The Search API stops at 1000 results, larger result sets are put together out of queries
+// narrowed down to creation date ranges small enough to stay under the cap.
+func (p *GitHubProvider) SearchRepos(ctx context.Context, query string) ([]*Repository, error) {
+	repos, total, err := p.searchAll(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while searching '%s': %w", query, err)
+	}
+	if total <= searchResultCap {
+		return repos, nil
+	}
+	if strings.Contains(query, "created:") {
+		return nil, fmt.Errorf("search '%s' has %d results, over the limit of %d, and can't be split as it already has a created qualifier",
+			query, total, searchResultCap)
+	}
+
+	found := make(map[string]bool)
+	repos = nil
+	err = p.searchRange(ctx, query, searchEpoch, time.Now().UTC().Truncate(time.Second), func(page []*Repository) {
+		for _, repo := range page {
+			if !found[repo.key()] {
+				found[repo.key()] = true
+				repos = append(repos, repo)
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while searching '%s': %w", query, err)
+	}
+	return repos, nil
+}
+
+// searchRange searches the repositories created between from and to, both included,
+// halving the range until every part of it is under the result cap
+func (p *GitHubProvider) searchRange(ctx context.Context, query string, from, to time.Time, collect func([]*Repository)) error {
+	q := fmt.Sprintf("%s created:%s..%s", query, from.Format(searchTimeLayout), to.Format(searchTimeLayout))
+	repos, total, err := p.searchAll(ctx, q)
+	if err != nil {
+		return err
+	}
+	if total <= searchResultCap {
+		collect(repos)
+		return nil
+	}
+
+	if !to.After(from) {
+		return fmt.Errorf("more than %d repositories were created at %s, the search can't be split any further",
+			searchResultCap, from.Format(searchTimeLayout))
+	}
+	mid := from.Add(to.Sub(from) / 2).Truncate(time.Second)
+	if err := p.searchRange(ctx, query, from, mid, collect); err != nil {
+		return err
+	}
+	return p.searchRange(ctx, query, mid.Add(time.Second), to, collect)
+}
+
+// searchAll pages through the results of a search query and returns them along with their total count.
+// Queries with more results than the cap stop after the first page, returning no repositories.
+func (p *GitHubProvider) searchAll(ctx context.Context, q string) ([]*Repository, int, error) {
+	query := url.Values{"q": {q}, "per_page": {"100"}}
+
+	var (
+		repos []*Repository
+		total int
+	)
+	for page := 1; page != 0; {
+		if page > 1 {
+			query.Set("page", strconv.Itoa(page))
+		}
+		req, err := p.Client.NewRequest(http.MethodGet, "search/repositories?"+query.Encode(), nil)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var result struct {
+			TotalCount int               `json:"total_count"`
+			Items      []json.RawMessage `json:"items"`
+		}
+		resp, err := p.Client.Do(ctx, req, &result)
+		if err != nil {
+			return nil, 0, err
+		}
+		total = result.TotalCount
+		if total > searchResultCap {
+			return nil, total, nil
+		}
+		for _, raw := range result.Items {
+			repo, err := p.decode(raw)
+			if err != nil {
+				return nil, 0, err
+			}
+			repos = append(repos, repo)
+		}
+		page = resp.NextPage
+	}
+
+	return repos, total, nil
+}
@@ -0,0 +1,98 @@
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// createdRange matches the created qualifier added when splitting searches
+var createdRange = regexp.MustCompile(` created:(\S+)\.\.(\S+)$`)
+
+// newSearchServer serves the Search API over repositories created a day apart, answering only with
+// the total count for the queries over the cap, like GitHub does past the 1000th result
+func newSearchServer(t *testing.T, count int, queries *int) *httptest.Server {
+	t.Helper()
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*queries++
+		from, to := searchEpoch, time.Now()
+		if m := createdRange.FindStringSubmatch(r.URL.Query().Get("q")); m != nil {
+			from, _ = time.Parse(searchTimeLayout, m[1])
+			to, _ = time.Parse(searchTimeLayout, m[2])
+		}
+
+		var items []map[string]any
+		for i := 0; i < count; i++ {
+			created := start.AddDate(0, 0, i)
+			if created.Before(from) || created.After(to) {
+				continue
+			}
+			items = append(items, map[string]any{
+				"name":       fmt.Sprintf("repo-%d", i),
+				"owner":      map[string]any{"login": "acme"},
+				"created_at": created,
+			})
+		}
+
+		result := map[string]any{"total_count": len(items)}
+		if len(items) <= searchResultCap {
+			result["items"] = items
+		}
+		_ = json.NewEncoder(w).Encode(result)
+	}))
+}
+
+func TestGitHubProvider_SearchRepos(t *testing.T) {
+	defer func(cap int) { searchResultCap = cap }(searchResultCap)
+	searchResultCap = 4
+
+	var queries int
+	server := newSearchServer(t, 3, &queries)
+	defer server.Close()
+
+	provider := NewGitHubProvider("github.com", newTestGitHubClient(t, server))
+	repos, err := provider.SearchRepos(context.Background(), "org:acme")
+	if err != nil {
+		t.Fatalf("SearchRepos() returned an error: %v", err)
+	}
+	if len(repos) != 3 || queries != 1 {
+		t.Errorf("SearchRepos() returned %d repos in %d queries, expected 3 repos in a single query", len(repos), queries)
+	}
+}
+
+func TestGitHubProvider_SearchRepos_SplitsOverTheCap(t *testing.T) {
+	defer func(cap int) { searchResultCap = cap }(searchResultCap)
+	searchResultCap = 4
+
+	var queries int
+	server := newSearchServer(t, 25, &queries)
+	defer server.Close()
+
+	provider := NewGitHubProvider("github.com", newTestGitHubClient(t, server))
+	repos, err := provider.SearchRepos(context.Background(), "org:acme")
+	if err != nil {
+		t.Fatalf("SearchRepos() returned an error: %v", err)
+	}
+
+	if len(repos) != 25 {
+		t.Fatalf("SearchRepos() returned %d repos, expected all 25", len(repos))
+	}
+	seen := make(map[string]bool)
+	for _, repo := range repos {
+		if seen[repo.Name] {
+			t.Errorf("%s was returned twice", repo.Name)
+		}
+		seen[repo.Name] = true
+	}
+
+	if _, err := provider.SearchRepos(context.Background(), "org:acme created:>2020-01-01"); err == nil {
+		t.Errorf("SearchRepos() split a query that already has a created qualifier")
+	}
+}
@@ -0,0 +1,138 @@
+package resolve
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultHost is the host of the owner/repo shorthand
+const DefaultHost = "github.com"
+
+var (
+	// scpLikeURL matches urls like git@github.com:owner/repo.git, capturing the host and the path
+	scpLikeURL = regexp.MustCompile(`^[a-zA-Z0-9_.-]+@([a-zA-Z0-9_.-]+):(.+)$`)
+	// shorthandURL matches owner/repo
+	shorthandURL = regexp.MustCompile(`^[a-zA-Z0-9_.-]+/[a-zA-Z0-9_.-]+$`)
+)
+
+// webPathMarkers are the path segments that follow owner/repo in a url pasted from a GitHub or Gitea browser,
+// like the "tree" in https://github.com/owner/repo/tree/main/dir. GitLab separates them with a "-" instead,
+// as in group/subgroup/repo/-/blob/main/file, since its subgroups can have any name.
+var webPathMarkers = []string{
+	"tree", "blob", "raw", "commit", "commits", "pull", "pulls", "issues", "releases", "tags",
+	"branches", "actions", "wiki", "wikis", "src", "compare", "settings",
+}
+
+// RepoRef is the canonical identity of a repository, or of an owner when Name is empty.
+// Owner can have several segments for GitLab subgroups.
+type RepoRef struct {
+	Host  string
+	Owner string
+	Name  string
+}
+
+// IsOwner tells if the reference is to an owner (an org, group or user) instead of a single repository
+func (r RepoRef) IsOwner() bool {
+	return r.Name == ""
+}
+
+// String returns the canonical form: host/owner/name, or host/owner for owners
+func (r RepoRef) String() string {
+	if r.IsOwner() {
+		return r.Host + "/" + r.Owner
+	}
+	return r.Host + "/" + r.Owner + "/" + r.Name
+}
+
+// HTTPSURL returns the https url of the repository, or of the owner's page
+func (r RepoRef) HTTPSURL() string {
+	if r.IsOwner() {
+		return "https://" + r.String()
+	}
+	return "https://" + r.String() + ".git"
+}
+
+// ParseRepoURL turns every common form of a git url into a canonical repository identity:
+//   - https://host/owner/repo, with or without .git and a trailing slash
+//   - https://host/owner, for owners
+//   - ssh://git@host:port/owner/repo.git
+//   - git@host:owner/repo, with or without .git
+//   - owner/repo, for github.com
+//   - @me, the authenticated user of github.com
+//   - tree, blob and other urls pasted from a browser, like https://host/owner/repo/tree/main/dir
+func ParseRepoURL(raw string) (RepoRef, error) {
+	raw = strings.TrimSpace(raw)
+
+	var host, path string
+	switch {
+	case raw == Me:
+		host, path = DefaultHost, raw
+	case shorthandURL.MatchString(raw):
+		host, path = DefaultHost, raw
+	case strings.Contains(raw, "://"):
+		u, err := url.Parse(raw)
+		if err != nil {
+			return RepoRef{}, fmt.Errorf("invalid URL '%s': %w", raw, err)
+		}
+		switch u.Scheme {
+		case "https", "http", "ssh", "git", "git+ssh", "ssh+git":
+		default:
+			return RepoRef{}, fmt.Errorf("unsupported scheme '%s' in URL '%s'", u.Scheme, raw)
+		}
+		host, path = u.Hostname(), u.Path
+	default:
+		m := scpLikeURL.FindStringSubmatch(raw)
+		if m == nil {
+			return RepoRef{}, fmt.Errorf("unrecognized git URL '%s'", raw)
+		}
+		host, path = m[1], m[2]
+	}
+
+	if host == "" {
+		return RepoRef{}, fmt.Errorf("URL '%s' has no host", raw)
+	}
+
+	segments := repoSegments(strings.ToLower(host), pathSegments(path))
+	if len(segments) == 0 {
+		return RepoRef{}, fmt.Errorf("URL '%s' has no owner", raw)
+	}
+
+	ref := RepoRef{Host: strings.ToLower(host)}
+	if len(segments) == 1 {
+		ref.Owner = segments[0]
+		return ref, nil
+	}
+	ref.Owner = strings.Join(segments[:len(segments)-1], "/")
+	ref.Name = strings.TrimSuffix(segments[len(segments)-1], ".git")
+	if ref.Name == "" {
+		return RepoRef{}, fmt.Errorf("URL '%s' has no repository name", raw)
+	}
+
+	return ref, nil
+}
+
+// repoSegments drops the segments of a browser url that follow the repository: the ones after GitLab's "-",
+// or after a web path marker right behind owner/repo, except on GitLab hosts where that would cut subgroups
+func repoSegments(host string, segments []string) []string {
+	if i := slices.Index(segments, "-"); i >= 2 {
+		return segments[:i]
+	}
+	if kind, _ := KnownKind(host); kind != KindGitLab && len(segments) > 2 && slices.Contains(webPathMarkers, segments[2]) {
+		return segments[:2]
+	}
+	return segments
+}
+
+// pathSegments splits a url path on slashes, dropping the empty segments
+func pathSegments(path string) []string {
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
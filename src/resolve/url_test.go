@@ -0,0 +1,83 @@
+package resolve
+
+import "testing"
+
+func TestParseRepoURL(t *testing.T) {
+	repo := RepoRef{Host: "github.com", Owner: "acme", Name: "api"}
+
+	tests := []struct {
+		raw      string
+		expected RepoRef
+	}{
+		{"https://github.com/acme/api", repo},
+		{"https://github.com/acme/api.git", repo},
+		{"https://github.com/acme/api/", repo},
+		{"https://github.com/acme/api.git/", repo},
+		{"http://GitHub.com/acme/api", repo},
+		{"git@github.com:acme/api.git", repo},
+		{"git@github.com:acme/api", repo},
+		{"ssh://git@github.com/acme/api.git", repo},
+		{"ssh://git@github.com:22/acme/api.git", repo},
+		{"git://github.com/acme/api.git", repo},
+		{"acme/api", repo},
+		{"https://github.com/acme/api/tree/main/cmd/server", repo},
+		{"https://github.com/acme/api/blob/main/README.md", repo},
+		{"https://github.com/acme/api/pull/42", repo},
+		{"https://github.com/acme", RepoRef{Host: "github.com", Owner: "acme"}},
+		{"https://github.com/acme/", RepoRef{Host: "github.com", Owner: "acme"}},
+		{"@me", RepoRef{Host: "github.com", Owner: Me}},
+		{"https://gitlab.com/@me", RepoRef{Host: "gitlab.com", Owner: Me}},
+		{"https://gitlab.com/acme/platform/api", RepoRef{Host: "gitlab.com", Owner: "acme/platform", Name: "api"}},
+		{"https://gitlab.com/acme/platform/api/-/tree/main", RepoRef{Host: "gitlab.com", Owner: "acme/platform", Name: "api"}},
+		{"git@gitlab.com:acme/platform/api.git", RepoRef{Host: "gitlab.com", Owner: "acme/platform", Name: "api"}},
+		{"https://gitlab.com/acme/platform/wiki", RepoRef{Host: "gitlab.com", Owner: "acme/platform", Name: "wiki"}},
+		{"https://gitlab.com/acme/tags/api", RepoRef{Host: "gitlab.com", Owner: "acme/tags", Name: "api"}},
+		{"https://gitlab.com/acme/platform/tree/-/tree/main", RepoRef{Host: "gitlab.com", Owner: "acme/platform", Name: "tree"}},
+		{"git@gitlab.com:acme/releases/api.git", RepoRef{Host: "gitlab.com", Owner: "acme/releases", Name: "api"}},
+		{"https://github.com/acme/tags", RepoRef{Host: "github.com", Owner: "acme", Name: "tags"}},
+		{"https://github.com/acme/api/wiki", repo},
+		{"https://codeberg.org/acme/api/src/branch/main/go.mod", RepoRef{Host: "codeberg.org", Owner: "acme", Name: "api"}},
+		{"ssh://git@git.corp.example:2222/acme/api", RepoRef{Host: "git.corp.example", Owner: "acme", Name: "api"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			ref, err := ParseRepoURL(tt.raw)
+			if err != nil {
+				t.Fatalf("ParseRepoURL() returned an error: %v", err)
+			}
+			if ref != tt.expected {
+				t.Errorf("ParseRepoURL() = %+v, expected %+v", ref, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseRepoURL_Invalid(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"api",
+		"/home/me/api",
+		"file:///home/me/api",
+		"https://github.com",
+		"https://github.com/acme/.git",
+		"ftp://github.com/acme/api",
+	} {
+		if ref, err := ParseRepoURL(raw); err == nil {
+			t.Errorf("ParseRepoURL(%q) = %+v, expected an error", raw, ref)
+		}
+	}
+}
+
+func TestRepoRef_Canonical(t *testing.T) {
+	forms := []string{"git@github.com:acme/api.git", "https://github.com/acme/api/tree/main", "acme/api"}
+	for _, raw := range forms {
+		ref, err := ParseRepoURL(raw)
+		if err != nil {
+			t.Fatalf("ParseRepoURL(%q) returned an error: %v", raw, err)
+		}
+		if ref.String() != "github.com/acme/api" || ref.HTTPSURL() != "https://github.com/acme/api.git" {
+			t.Errorf("%q has the canonical form %q, https url %q", raw, ref.String(), ref.HTTPSURL())
+		}
+	}
+}
@@ -0,0 +1,279 @@
+// Package retry tries transient failures of API calls, clones and fetches again, with an exponential backoff.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// Class is a kind of transient failure a Policy can retry
+type Class string
+
+const (
+	// Network failures: connections refused, reset or cut short
+	Network Class = "network"
+	// Timeout failures: connections or responses that took too long
+	Timeout Class = "timeout"
+	// Server failures: 5xx responses
+	Server Class = "server"
+	// RateLimit failures: 429 responses the rate limit manager gave up on
+	RateLimit Class = "rate_limit"
+)
+
+// Classes are the known failure classes
+var Classes = []Class{Network, Timeout, Server, RateLimit}
+
+const (
+	DefaultAttempts  = 3
+	DefaultBaseDelay = time.Second
+	DefaultMaxDelay  = 30 * time.Second
+	DefaultJitter    = 0.2
+)
+
+// DefaultRetryOn are the classes retried when a policy doesn't list any
+var DefaultRetryOn = []Class{Network, Timeout, Server}
+
+var (
+	// gitStatusOutput matches the http status git prints for failed requests, like "RPC failed; HTTP 502"
+	// or "The requested URL returned error: 503"
+	gitStatusOutput = regexp.MustCompile(`(?:HTTP|returned error:) (\d{3})\b`)
+	// gitTransientOutput are the lowercased messages git prints for transient failures, with their class
+	gitTransientOutput = []struct {
+		message string
+		class   Class
+	}{
+		{"could not resolve host", Network},
+		{"failed to connect to", Network},
+		{"rpc failed", Network},
+		{"early eof", Network},
+		{"unexpected disconnect", Network},
+		{"the remote end hung up unexpectedly", Network},
+		{"connection reset", Network},
+		{"connection refused", Network},
+		{"timed out", Timeout},
+	}
+)
+
+// GitError is a failure of the git binary. What git printed to stderr tells if it's transient.
+type GitError struct {
+	Err    error
+	Stderr string
+}
+
+func (e *GitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *GitError) Unwrap() error {
+	return e.Err
+}
+
+// Policy tells how many times an operation is tried and how long to wait in between.
+// The delay before the n-th retry is BaseDelay doubled n-1 times, capped at MaxDelay,
+// and a random share of it up to Jitter is taken off so that parallel retries don't hit the server at once.
+// Only the failures of the RetryOn classes are retried. A nil *Policy tries operations once.
+type Policy struct {
+	Attempts  int // tries in total, including the first one
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Jitter    float64 // from 0 to 1
+	RetryOn   []Class
+
+	sleep  func(ctx context.Context, d time.Duration) error
+	random func() float64
+}
+
+// NewPolicy returns the default policy
+func NewPolicy() *Policy {
+	return &Policy{
+		Attempts:  DefaultAttempts,
+		BaseDelay: DefaultBaseDelay,
+		MaxDelay:  DefaultMaxDelay,
+		Jitter:    DefaultJitter,
+		RetryOn:   DefaultRetryOn,
+	}
+}
+
+// Validate returns an error for the settings that make no sense
+func (p *Policy) Validate() error {
+	if p.Attempts < 1 {
+		return fmt.Errorf("retry attempts must be at least 1, got %d", p.Attempts)
+	}
+	if p.BaseDelay < 0 || p.MaxDelay < 0 {
+		return fmt.Errorf("retry delays can't be negative")
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("retry jitter must be between 0 and 1, got %v", p.Jitter)
+	}
+	for _, c := range p.RetryOn {
+		if !slices.Contains(Classes, c) {
+			return fmt.Errorf("unknown retry class '%s', valid values are %s", c, joinClasses(Classes))
+		}
+	}
+	return nil
+}
+
+// Delay returns how long to wait before the given retry, counted from 1
+func (p *Policy) Delay(retry int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < retry && (p.MaxDelay == 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(float64(d) * p.Jitter * p.rand())
+	}
+	return d
+}
+
+// Retryable tells whether err belongs to one of the classes the policy retries
+func (p *Policy) Retryable(err error) bool {
+	class, ok := Classify(err)
+	return ok && p.retries(class)
+}
+
+func (p *Policy) retries(class Class) bool {
+	return slices.Contains(p.RetryOn, class)
+}
+
+// Do runs op until it succeeds, fails for good or runs out of attempts, and returns how many times it was retried.
+// onRetry, when not nil, is called with the failure before waiting to retry.
+// It stops waiting when ctx is canceled, returning the last failure.
+func (p *Policy) Do(ctx context.Context, op func() error, onRetry func(err error, wait time.Duration)) (int, error) {
+	for retries := 0; ; retries++ {
+		err := op()
+		if err == nil || p == nil || retries+1 >= p.Attempts || ctx.Err() != nil || !p.Retryable(err) {
+			return retries, err
+		}
+
+		wait := p.Delay(retries + 1)
+		if onRetry != nil {
+			onRetry(err, wait)
+		}
+		if p.wait(ctx, wait) != nil {
+			return retries, err
+		}
+	}
+}
+
+func (p *Policy) wait(ctx context.Context, d time.Duration) error {
+	if p.sleep != nil {
+		return p.sleep(ctx, d)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (p *Policy) rand() float64 {
+	if p.random != nil {
+		return p.random()
+	}
+	return rand.Float64()
+}
+
+// Classify returns the class of a transient failure, or false for the failures that won't go away by trying again,
+// like a repository that doesn't exist, a denied authentication or a canceled run.
+// The errors of go-git's transports are classified through their wrappers.
+func Classify(err error) (Class, bool) {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return "", false
+	}
+
+	var unexpected *plumbing.UnexpectedError
+	if errors.As(err, &unexpected) {
+		return Classify(unexpected.Err)
+	}
+	var permanent *plumbing.PermanentError
+	if errors.As(err, &permanent) {
+		return Classify(permanent.Err)
+	}
+	var gitErr *GitError
+	if errors.As(err, &gitErr) {
+		return ClassifyGitOutput(gitErr.Stderr)
+	}
+	var httpErr *githttp.Err
+	if errors.As(err, &httpErr) && httpErr.Response != nil {
+		return ClassifyStatus(httpErr.Response.StatusCode)
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsNotFound {
+			return "", false
+		}
+		if dnsErr.IsTimeout {
+			return Timeout, true
+		}
+		return Network, true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return Timeout, true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ENETUNREACH) || errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return Network, true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return Network, true
+	}
+	return "", false
+}
+
+// ClassifyStatus returns the class of a transient http status
+func ClassifyStatus(status int) (Class, bool) {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return RateLimit, true
+	case status >= http.StatusInternalServerError && status != http.StatusNotImplemented:
+		return Server, true
+	}
+	return "", false
+}
+
+// ClassifyGitOutput returns the class of a transient failure of the git binary out of what it printed to stderr
+func ClassifyGitOutput(stderr string) (Class, bool) {
+	if m := gitStatusOutput.FindStringSubmatch(stderr); m != nil {
+		status, _ := strconv.Atoi(m[1])
+		return ClassifyStatus(status)
+	}
+	stderr = strings.ToLower(stderr)
+	for _, o := range gitTransientOutput {
+		if strings.Contains(stderr, o.message) {
+			return o.class, true
+		}
+	}
+	return "", false
+}
+
+func joinClasses(classes []Class) string {
+	names := make([]string, len(classes))
+	for i, c := range classes {
+		names[i] = string(c)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,139 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// newTestPolicy returns a policy that records its waits instead of sleeping, without jitter
+func newTestPolicy(attempts int, waits *[]time.Duration) *Policy {
+	p := NewPolicy()
+	p.Attempts = attempts
+	p.Jitter = 0
+	p.sleep = func(_ context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return nil
+	}
+	return p
+}
+
+func TestPolicy_Delay(t *testing.T) {
+	p := &Policy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	for retry, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := p.Delay(retry); got != expected {
+			t.Errorf("Delay(%d) = %v, expected %v", retry, got, expected)
+		}
+	}
+
+	p.Jitter, p.random = 0.5, func() float64 { return 1 }
+	if got := p.Delay(2); got != time.Second {
+		t.Errorf("Delay(2) = %v with the whole jitter taken off, expected 1s", got)
+	}
+}
+
+func TestPolicy_Do(t *testing.T) {
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+
+	tests := []struct {
+		name     string
+		failures []error
+		retries  int
+		waits    []time.Duration
+		fails    bool
+	}{
+		{name: "success", retries: 0},
+		{name: "transient failures", failures: []error{reset, reset}, retries: 2, waits: []time.Duration{time.Second, 2 * time.Second}},
+		{name: "out of attempts", failures: []error{reset, reset, reset, reset}, retries: 2, waits: []time.Duration{time.Second, 2 * time.Second}, fails: true},
+		{name: "permanent failure", failures: []error{transport.ErrRepositoryNotFound}, retries: 0, fails: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var waits []time.Duration
+			p := newTestPolicy(3, &waits)
+
+			calls := 0
+			retries, err := p.Do(context.Background(), func() error {
+				calls++
+				if calls <= len(tt.failures) {
+					return tt.failures[calls-1]
+				}
+				return nil
+			}, nil)
+
+			if retries != tt.retries || (err != nil) != tt.fails {
+				t.Errorf("Do() = %d, %v, expected %d retries", retries, err, tt.retries)
+			}
+			if fmt.Sprint(waits) != fmt.Sprint(tt.waits) {
+				t.Errorf("Do() waited %v, expected %v", waits, tt.waits)
+			}
+		})
+	}
+}
+
+func TestPolicy_DoStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewPolicy()
+
+	calls := 0
+	_, err := p.Do(ctx, func() error {
+		calls++
+		cancel()
+		return io.ErrUnexpectedEOF
+	}, nil)
+	if calls != 1 || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Do() made %d calls and returned %v, expected to stop after the cancellation", calls, err)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	serverErr := plumbing.NewUnexpectedError(&githttp.Err{Response: &http.Response{StatusCode: http.StatusBadGateway}})
+	refused := plumbing.NewUnexpectedError(&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)})
+
+	tests := []struct {
+		err       error
+		class     Class
+		transient bool
+	}{
+		{err: serverErr, class: Server, transient: true},
+		{err: fmt.Errorf("error occurred while cloning repo: %w", refused), class: Network, transient: true},
+		{err: fmt.Errorf("ssh: handshake failed: %w", io.EOF), class: Network, transient: true},
+		{err: os.ErrDeadlineExceeded, class: Timeout, transient: true},
+		{err: &net.DNSError{Err: "no such host", Name: "gitub.com", IsNotFound: true}},
+		{err: transport.ErrAuthenticationRequired},
+		{err: context.Canceled},
+		{err: &GitError{Err: errors.New("git clone: exit status 128"), Stderr: "fatal: unable to access 'https://github.com/acme/api.git/': Could not resolve host: github.com\n"}, class: Network, transient: true},
+		{err: &GitError{Err: errors.New("git fetch: exit status 128"), Stderr: "error: RPC failed; HTTP 502 curl 22 The requested URL returned error: 502\n"}, class: Server, transient: true},
+		{err: &GitError{Err: errors.New("git fetch: exit status 128"), Stderr: "error: RPC failed; curl 18 transfer closed with outstanding read data remaining\nfatal: early EOF\n"}, class: Network, transient: true},
+		{err: &GitError{Err: errors.New("git clone: exit status 128"), Stderr: "fatal: unable to access 'https://github.com/acme/api.git/': The requested URL returned error: 403\n"}},
+		{err: &GitError{Err: errors.New("git clone: exit status 128"), Stderr: "remote: Repository not found.\nfatal: repository 'https://github.com/acme/nope.git/' not found\n"}},
+	}
+	for _, tt := range tests {
+		class, transient := Classify(tt.err)
+		if class != tt.class || transient != tt.transient {
+			t.Errorf("Classify(%v) = %q, %v, expected %q, %v", tt.err, class, transient, tt.class, tt.transient)
+		}
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	p := NewPolicy()
+	if err := p.Validate(); err != nil {
+		t.Errorf("Validate() returned an error for the default policy: %v", err)
+	}
+	p.RetryOn = []Class{Network, "flaky"}
+	if err := p.Validate(); err == nil {
+		t.Errorf("Validate() did not return an error for an unknown class")
+	}
+}
@@ -0,0 +1,83 @@
+package retry
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/florinutz/git-intel/src/internal/httpreq"
+)
+
+// Transport is an http.RoundTripper sending the requests that fail transiently again, as its Policy tells.
+// Requests whose body can't be rewound are sent once. It is safe for concurrent use.
+type Transport struct {
+	Base   http.RoundTripper // defaults to http.DefaultTransport
+	Policy *Policy
+	// OnRetry is called before waiting to send a request again, with the failure or the failed response's status
+	OnRetry func(req *http.Request, failure string, wait time.Duration)
+
+	retries atomic.Int64
+}
+
+func NewTransport(policy *Policy, base http.RoundTripper) *Transport {
+	return &Transport{Base: base, Policy: policy}
+}
+
+// Retries returns how many requests were sent again so far
+func (t *Transport) Retries() int {
+	return int(t.retries.Load())
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	rewindable := req.Body == nil || req.GetBody != nil
+
+	for attempt := 1; ; attempt++ {
+		send := req
+		if attempt > 1 {
+			var err error
+			if send, err = httpreq.Rewind(req); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := t.base().RoundTrip(send)
+
+		class, transient := classifyRoundTrip(resp, err)
+		if !transient || t.Policy == nil || !t.Policy.retries(class) || attempt >= t.Policy.Attempts ||
+			!rewindable || ctx.Err() != nil {
+			return resp, err
+		}
+
+		failure := fmt.Sprint(err)
+		if err == nil {
+			failure = resp.Status
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		wait := t.Policy.Delay(attempt)
+		if t.OnRetry != nil {
+			t.OnRetry(req, failure, wait)
+		}
+		if err := t.Policy.wait(ctx, wait); err != nil {
+			return nil, err
+		}
+		t.retries.Add(1)
+	}
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// classifyRoundTrip returns the class of a failed round trip, by its error or by the status of its response
+func classifyRoundTrip(resp *http.Response, err error) (Class, bool) {
+	if err != nil {
+		return Classify(err)
+	}
+	return ClassifyStatus(resp.StatusCode)
+}
@@ -0,0 +1,67 @@
+package retry
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransport_RetriesServerErrors(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = io.WriteString(w, `{"data": {}}`)
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	rt := NewTransport(newTestPolicy(3, &waits), nil)
+	var failures []string
+	rt.OnRetry = func(_ *http.Request, failure string, _ time.Duration) { failures = append(failures, failure) }
+
+	client := &http.Client{Transport: rt}
+	resp, err := client.Post(server.URL+"/graphql", "application/json", strings.NewReader(`{"query": "{ viewer { login } }"}`))
+	if err != nil {
+		t.Fatalf("Failed to send the request: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || rt.Retries() != 2 {
+		t.Errorf("RoundTrip() returned %d after %d retries, expected success after 2", resp.StatusCode, rt.Retries())
+	}
+	if len(bodies) != 3 || bodies[2] != bodies[0] {
+		t.Errorf("the retries were sent with the bodies %q", bodies)
+	}
+	if len(failures) != 2 || failures[0] != "502 Bad Gateway" {
+		t.Errorf("OnRetry() was called with %q", failures)
+	}
+}
+
+func TestTransport_ReturnsPermanentFailures(t *testing.T) {
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	rt := NewTransport(newTestPolicy(3, &waits), nil)
+	resp, err := (&http.Client{Transport: rt}).Get(server.URL + "/orgs/nope/repos")
+	if err != nil {
+		t.Fatalf("Failed to send the request: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound || hits != 1 || rt.Retries() != 0 {
+		t.Errorf("RoundTrip() returned %d after %d hits, expected the 404 right away", resp.StatusCode, hits)
+	}
+}